@@ -1,37 +1,80 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/netip"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
+	"github.com/bellettati/go-rate-limited-api/internal/admin"
+	"github.com/bellettati/go-rate-limited-api/internal/apikeys"
 	"github.com/bellettati/go-rate-limited-api/internal/config"
 	"github.com/bellettati/go-rate-limited-api/internal/handlers"
 	"github.com/bellettati/go-rate-limited-api/internal/limiter"
+	"github.com/bellettati/go-rate-limited-api/internal/metrics"
 	"github.com/bellettati/go-rate-limited-api/internal/middleware"
+	"github.com/bellettati/go-rate-limited-api/internal/overrides"
+	"github.com/bellettati/go-rate-limited-api/internal/policy"
 	"github.com/bellettati/go-rate-limited-api/internal/store"
 )
 
 func main() {
-	cfg := config.LoadConfig()
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+	// A second signal kills the process instead of waiting for the drain.
+	context.AfterFunc(ctx, stop)
 
-	defaultLimit := limiter.LimitConfig{
-		Limit:  cfg.DefaultLimit,
-		Window: cfg.DefaultWindow,
+	if err := run(ctx, config.LoadConfig(), nil); err != nil {
+		slog.Error("server failed", "error", err)
+		os.Exit(1)
 	}
+}
 
-	overrides := map[string]limiter.LimitConfig{
-		"vip": {Limit: 3, Window: time.Minute},
-	}
+// Deferred cleanup only runs because run returns instead of exiting.
+func run(ctx context.Context, cfg config.Config, ready func(addr string)) error {
+	logger := newLogger(cfg.LogFormat, cfg.LogLevel)
+	slog.SetDefault(logger)
 
-	var requestLimiter limiter.Limiter
 	clock := limiter.RealClock{} 
 
+	reg := metrics.NewRegistry()
+	storeLatency := reg.NewHistogram("ratelimit_store_call_duration_seconds", "Store call latency by store and operation.", metrics.DefaultBuckets, "store", "op")
+	storeErrors := reg.NewCounter("ratelimit_store_errors_total", "Failed store calls by store and operation.", "store", "op")
+	observeStore := func(name string) store.ObserveFunc {
+		return func(op string, took time.Duration, err error) {
+			storeLatency.Observe(took.Seconds(), name, op)
+			if err != nil {
+				storeErrors.Inc(name, op)
+			}
+		}
+	}
+
+	var memoryStores []*store.MemoryStore
+	trackedKeys := reg.NewGauge("ratelimit_tracked_keys", "Keys held in process memory by source.", "source")
+	trackedKeys.SetFunc(func() float64 {
+		total := 0
+		for _, mem := range memoryStores {
+			total += mem.TrackedKeys()
+		}
+		return float64(total)
+	}, "memory_store")
+
 	var st store.Store	
+	var fallbackStore store.Store
+	var overrideStore overrides.Store
+	var redisStore *store.RedisStore
 	switch cfg.RateLimitBackend {
 	case config.InMemory:
-		st = store.NewMemoryStoreWithCleanupInterval(cfg.DefaultWindow)
+		mem := store.NewMemoryStoreWithCleanupInterval(cfg.CleanupInterval)
+		memoryStores = append(memoryStores, mem)
+		st = store.NewTimedStore(mem, observeStore("memory"))
+		overrideStore = overrides.NewMemoryStore()
 	case config.Redis:
 		rs, err := store.NewRedisStore(store.RedisConfig{
 			Addr: cfg.RedisAddr,
@@ -43,24 +86,121 @@ func main() {
 		})
 
 		if err != nil {
-			log.Fatal(err)
+			return fmt.Errorf("connect to redis: %w", err)
 		}
+		redisStore = rs
+
+		st = store.NewTimedStore(store.NewBreakerStore(rs, store.BreakerConfig{
+			FailureThreshold: cfg.RedisBreakerFailureThreshold,
+			OpenTimeout: cfg.RedisBreakerOpenTimeout,
+			CallTimeout: cfg.RedisCallTimeout,
+		}), observeStore("redis"))
 
-		st = rs
+		mem := store.NewMemoryStoreWithCleanupInterval(cfg.CleanupInterval)
+		memoryStores = append(memoryStores, mem)
+		fallbackStore = store.NewTimedStore(mem, observeStore("fallback"))
+
+		overrideStore = overrides.NewRedisStore(rs.Client())
 	default:
-		log.Fatalf("unsupported backend: %q", cfg.RateLimitBackend)
+		return fmt.Errorf("unsupported backend: %q", cfg.RateLimitBackend)
 	}
 	defer func() { _ = st.Close() }()
+	if fallbackStore != nil {
+		defer func() { _ = fallbackStore.Close() }()
+	}
 
-	switch cfg.RateLimitStrategy {
-	case config.FixedWindow:
-		requestLimiter = limiter.NewFixedWindowLimiter(st, clock, defaultLimit, overrides)
-	case config.SlidingWindow:
-		requestLimiter = limiter.NewSlidingWindowLimiter(clock, defaultLimit, overrides)
-	case config.TokenBucket:
-		requestLimiter = limiter.NewTokenBucketLimiter(clock, defaultLimit, overrides)
-	default:
-		log.Fatalf("unsupported rate limit strategy: %q", cfg.RateLimitStrategy)
+	queue := limiter.QueueConfig{Depth: cfg.QueueDepth, MaxWait: cfg.QueueMaxWait}
+	newLimiter, err := newLimiterFactory(cfg.RateLimitStrategy, clock, queue, cfg.CleanupInterval)
+	if err != nil {
+		return err
+	}
+
+	limiters := policy.New(policy.Config{
+		Store:       st,
+		Distributed: cfg.RateLimitBackend == config.Redis,
+		Fallback:    fallbackStore,
+		Replicas:    cfg.ExpectedReplicas,
+		Build:       newLimiter,
+		Clock:       clock,
+		Tiers:       cfg.APIKeyRegistry != "",
+		Logger:      logger,
+		Concurrency: cfg.MaxConcurrentRequests,
+		LeaseTTL:    cfg.ConcurrencyLeaseTTL,
+	}, cfg.Policy)
+	trackedKeys.SetFunc(func() float64 { return float64(limiters.TrackedKeys()) }, "limiter")
+
+	overrideManager := overrides.NewManager(overrideStore, overrides.ManagerConfig{
+		Apply:    limiters.ApplyOverrides,
+		Validate: limiters.ValidateOverride,
+		Logger:   logger.With("component", "overrides"),
+	})
+	if err := overrideManager.Sync(ctx); err != nil {
+		logger.Warn("loading overrides failed, starting without them", "error", err)
+	}
+	go overrideManager.Run(ctx, cfg.OverrideSyncInterval, func(err error) {
+		logger.Warn("override sync failed, keeping the current overrides", "error", err)
+	})
+
+	var keyRegistry *apikeys.Registry
+	if cfg.APIKeyRegistry != "" {
+		var keyStore apikeys.Store = apikeys.NewMemoryStore()
+		if cfg.APIKeyRegistry == config.Redis {
+			keyStore = apikeys.NewRedisStore(redisStore.Client())
+		}
+
+		keyRegistry = apikeys.NewRegistry(keyStore, apikeys.RegistryConfig{
+			CacheTTL: cfg.APIKeyCacheTTL,
+			Validate: limiters.ValidateKey,
+		})
+
+		if cfg.APIKeysFile != "" {
+			keys, err := apikeys.LoadFile(cfg.APIKeysFile)
+			if err != nil {
+				return err
+			}
+			added := 0
+			for _, k := range keys {
+				ok, err := keyRegistry.Add(ctx, k)
+				if err != nil {
+					return fmt.Errorf("API keys file %s: key of %s: %w", cfg.APIKeysFile, k.Owner, err)
+				}
+				if ok {
+					added++
+				}
+			}
+			logger.Info("API keys loaded", "file", cfg.APIKeysFile, "keys", len(keys), "added", added)
+		}
+	}
+
+	if cfg.PolicyFile != "" {
+		reloader := config.NewPolicyReloader(cfg.PolicyFile, cfg.Policy, limiters.ApplyPolicy)
+		report := func(changes []string, err error) {
+			if err != nil {
+				logger.Warn("policy reload rejected, keeping the current policy", "error", err)
+				return
+			}
+			for _, change := range changes {
+				logger.Info("policy reload", "change", change)
+			}
+		}
+
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		defer signal.Stop(hup)
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-hup:
+					report(reloader.Reload())
+				}
+			}
+		}()
+
+		if cfg.PolicyReloadInterval > 0 {
+			go reloader.Watch(ctx, cfg.PolicyReloadInterval, report)
+		}
 	}
 
 	mux := http.NewServeMux()
@@ -70,8 +210,189 @@ func main() {
 
 	mux.HandleFunc("/protected", handlers.Protected)
 
-	rateLimitedMux := middleware.RateLimit(requestLimiter)(mux)
+	failureMode := middleware.FailOpen
+	if cfg.RateLimitFailureMode == config.FailClosed {
+		failureMode = middleware.FailClosed
+	}
+
+	middlewareOpts := []middleware.Option{
+		middleware.WithFailureMode(failureMode, cfg.RateLimitFailureStatus),
+		middleware.WithHeaders(middleware.HeaderStyle(cfg.RateLimitHeaders)),
+		middleware.WithLogger(logger.With("strategy", string(cfg.RateLimitStrategy))),
+		middleware.WithMetrics(middleware.NewMetrics(reg)),
+	}
+	middlewareOpts = append(middlewareOpts,
+		middleware.WithKeyFunc(clientKeyFunc(cfg.ClientKey, cfg.TrustedProxies, limiters.RouteKey)),
+		middleware.WithCost(limiters.Cost),
+		middleware.WithRoutes(limiters.Route),
+		middleware.WithExempt(limiters.Exempt),
+	)
+	if keyRegistry != nil {
+		middlewareOpts = append(middlewareOpts, middleware.WithAPIKeys(keyRegistry, limiters.Tier))
+	}
+	if len(cfg.IPAllowlist) > 0 || len(cfg.IPDenylist) > 0 {
+		ipRules := middleware.NewIPRules(cfg.IPAllowlist, cfg.IPDenylist)
+		middlewareOpts = append(middlewareOpts, middleware.WithIPRules(ipRules, cfg.TrustedProxies...))
+	}
+	if cfg.RateLimitStrategy == config.LeakyBucket {
+		middlewareOpts = append(middlewareOpts, middleware.WithQueueing())
+	}
+	middlewareOpts = append(middlewareOpts, middleware.WithConcurrencyLimit(limiters.Concurrency()))
+
+	rateLimitedMux := middleware.RateLimit(limiters.Limiter(), middlewareOpts...)(mux)
+
+	newServer := func(addr string, handler http.Handler) *http.Server {
+		return &http.Server{
+			Addr:              addr,
+			Handler:           handler,
+			ReadTimeout:       cfg.HTTPReadTimeout,
+			ReadHeaderTimeout: cfg.HTTPReadHeaderTimeout,
+			WriteTimeout:      cfg.HTTPWriteTimeout,
+			IdleTimeout:       cfg.HTTPIdleTimeout,
+			ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+		}
+	}
+
+	servers := []*http.Server{newServer(cfg.ListenAddr, rateLimitedMux)}
+	if cfg.MetricsAddr != "" {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("GET /metrics", reg.Handler())
+		servers = append(servers, newServer(cfg.MetricsAddr, metricsMux))
+	}
+	if cfg.AdminAddr != "" {
+		servers = append(servers, newServer(cfg.AdminAddr, admin.NewHandler(limiters.Keys(), admin.Config{
+			Tokens:    cfg.AdminTokens,
+			Scopes:    limiters,
+			Overrides: overrideManager,
+			APIKeys:   keyRegistry,
+			Strategy:  string(cfg.RateLimitStrategy),
+			Logger:    logger.With("component", "admin"),
+		})))
+	}
+
+	listeners := make([]net.Listener, 0, len(servers))
+	for _, srv := range servers {
+		ln, err := net.Listen("tcp", srv.Addr)
+		if err != nil {
+			for _, l := range listeners {
+				_ = l.Close()
+			}
+			return fmt.Errorf("listen on %s: %w", srv.Addr, err)
+		}
+		listeners = append(listeners, ln)
+	}
+
+	serveErr := make(chan error, len(servers))
+	for i, srv := range servers {
+		ln := listeners[i]
+		go func() {
+			serveErr <- srv.Serve(ln)
+		}()
+		logger.Info("server running", "addr", ln.Addr().String())
+	}
+	if ready != nil {
+		ready(listeners[0].Addr().String())
+	}
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	logger.Info("shutting down, draining in-flight requests", "timeout", cfg.ShutdownTimeout)
+	drainCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	for _, srv := range servers {
+		if err := srv.Shutdown(drainCtx); err != nil {
+			logger.Warn("drain timed out, closing remaining connections", "addr", srv.Addr, "error", err)
+			_ = srv.Close()
+		}
+	}
+
+	// The stores are closed by the deferred calls above, after the limiters
+	// that use them.
+	_ = limiters.Close()
+
+	logger.Info("server stopped")
+	return nil
+}
+
+func newLogger(format config.LogFormat, level slog.Level) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level}
+	if format == config.LogJSON {
+		return slog.New(slog.NewJSONHandler(os.Stdout, opts))
+	}
+
+	return slog.New(slog.NewTextHandler(os.Stdout, opts))
+}
+
+func newLimiterFactory(
+	strategy config.RateLimitStrategy,
+	clock limiter.Clock,
+	queue limiter.QueueConfig,
+	cleanupInterval time.Duration,
+) (policy.BuildFunc, error) {
+	switch strategy {
+	case config.FixedWindow:
+		return func(st store.Store, _ bool, def limiter.LimitConfig, overrides map[string]limiter.LimitConfig) limiter.Limiter {
+			return limiter.NewFixedWindowLimiter(st, clock, def, overrides)
+		}, nil
+	case config.SlidingWindow:
+		return func(st store.Store, distributed bool, def limiter.LimitConfig, overrides map[string]limiter.LimitConfig) limiter.Limiter {
+			if distributed {
+				return limiter.NewSlidingWindowCounterLimiter(st, clock, def, overrides)
+			}
+			return limiter.NewSlidingWindowLimiterWithCleanupInterval(clock, def, overrides, cleanupInterval)
+		}, nil
+	case config.TokenBucket:
+		return func(st store.Store, distributed bool, def limiter.LimitConfig, overrides map[string]limiter.LimitConfig) limiter.Limiter {
+			if distributed {
+				return limiter.NewDistributedTokenBucketLimiter(st, clock, def, overrides)
+			}
+			return limiter.NewTokenBucketLimiterWithCleanupInterval(clock, def, overrides, cleanupInterval)
+		}, nil
+	case config.GCRA:
+		return func(st store.Store, _ bool, def limiter.LimitConfig, overrides map[string]limiter.LimitConfig) limiter.Limiter {
+			return limiter.NewGCRALimiter(st, clock, def, overrides)
+		}, nil
+	case config.LeakyBucket:
+		return func(st store.Store, _ bool, def limiter.LimitConfig, overrides map[string]limiter.LimitConfig) limiter.Limiter {
+			return limiter.NewLeakyBucketLimiter(st, clock, def, overrides, queue)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported rate limit strategy: %q", strategy)
+	}
+}
+
+func clientKeyFunc(alternatives [][]config.KeyPart, trusted []netip.Prefix, route middleware.KeyFunc) middleware.KeyFunc {
+	fns := make([]middleware.KeyFunc, 0, len(alternatives))
+	for _, parts := range alternatives {
+		composite := make([]middleware.KeyFunc, 0, len(parts))
+		for _, part := range parts {
+			switch part.Source {
+			case config.KeyAPIKey:
+				composite = append(composite, middleware.APIKey())
+			case config.KeyHeader:
+				composite = append(composite, middleware.Header(part.Name))
+			case config.KeyQuery:
+				composite = append(composite, middleware.QueryParam(part.Name))
+			case config.KeyBearer:
+				composite = append(composite, middleware.BearerSubject())
+			case config.KeyIP:
+				composite = append(composite, middleware.ClientIP(trusted...))
+			case config.KeyRoute:
+				composite = append(composite, route)
+			}
+		}
+
+		if len(composite) == 1 {
+			fns = append(fns, composite[0])
+			continue
+		}
+		fns = append(fns, middleware.Composite(composite...))
+	}
 
-	log.Println("Server running on :8080")
-	log.Fatal(http.ListenAndServe(":8080", rateLimitedMux))
+	return middleware.FirstOf(fns...)
 }
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"testing"
+	"time"
+
+	"github.com/bellettati/go-rate-limited-api/internal/config"
+)
+
+func TestRunDrainsInFlightRequestsOnShutdown(t *testing.T) {
+	// With a leaky bucket of one request a second, the second request is
+	// queued for about a second: long enough to shut down while it is in
+	// flight.
+	t.Setenv("LISTEN_ADDR", "127.0.0.1:0")
+	t.Setenv("RATE_LIMIT_STRATEGY", "leaky_bucket")
+	t.Setenv("DEFAULT_LIMIT", "1")
+	t.Setenv("DEFAULT_WINDOW_SECONDS", "1")
+	t.Setenv("SHUTDOWN_TIMEOUT_SECONDS", "5")
+	t.Setenv("LOG_LEVEL", "error")
+	cfg := config.LoadConfig()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	addr := make(chan string, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- run(ctx, cfg, func(a string) { addr <- a })
+	}()
+
+	var url string
+	select {
+	case a := <-addr:
+		url = "http://" + a + "/protected"
+	case err := <-done:
+		t.Fatalf("run returned before listening: %v", err)
+	}
+
+	// Both requests share one connection, so the client never opens a
+	// spare one that the drain would wait on.
+	client := &http.Client{Transport: &http.Transport{}}
+	defer client.CloseIdleConnections()
+	get := func(ctx context.Context) (int, error) {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		req.Header.Set("X-API-Key", "test-key")
+		res, err := client.Do(req)
+		if err != nil {
+			return 0, err
+		}
+		defer res.Body.Close()
+		_, err = io.Copy(io.Discard, res.Body)
+		return res.StatusCode, err
+	}
+
+	if _, err := get(context.Background()); err != nil {
+		t.Fatalf("first request: %v", err)
+	}
+
+	sent := make(chan struct{})
+	trace := &httptrace.ClientTrace{WroteRequest: func(httptrace.WroteRequestInfo) { close(sent) }}
+	inFlight := make(chan int, 1)
+	go func() {
+		status, err := get(httptrace.WithClientTrace(context.Background(), trace))
+		if err != nil {
+			t.Errorf("in-flight request: %v", err)
+		}
+		inFlight <- status
+	}()
+
+	<-sent
+	time.Sleep(100 * time.Millisecond)
+	start := time.Now()
+	cancel()
+
+	if status := <-inFlight; status != http.StatusOK {
+		t.Fatalf("expected the queued request to be served during the drain, got %d", status)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected run error: %v", err)
+		}
+	case <-time.After(cfg.ShutdownTimeout):
+		t.Fatalf("run did not return within SHUTDOWN_TIMEOUT")
+	}
+	if took := time.Since(start); took >= cfg.ShutdownTimeout {
+		t.Fatalf("expected the drain to finish within %s, took %s", cfg.ShutdownTimeout, took)
+	}
+
+	if _, err := get(context.Background()); err == nil {
+		t.Fatalf("expected the server to stop accepting requests")
+	}
+}
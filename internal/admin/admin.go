@@ -0,0 +1,284 @@
+// Package admin serves the operator API, on a listener clients cannot reach.
+package admin
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bellettati/go-rate-limited-api/internal/apikeys"
+	"github.com/bellettati/go-rate-limited-api/internal/clientkey"
+	"github.com/bellettati/go-rate-limited-api/internal/limiter"
+	"github.com/bellettati/go-rate-limited-api/internal/overrides"
+)
+
+const (
+	defaultTopKeys = 20
+	maxTopKeys     = 1000
+
+	defaultAuditEntries = 100
+	maxAuditEntries     = 1000
+
+	maxBodyBytes = 64 << 10
+)
+
+type Config struct {
+	Tokens    map[string]string
+	Overrides *overrides.Manager
+	APIKeys   *apikeys.Registry
+	Scopes    Scopes
+	Strategy  string
+	Logger    *slog.Logger
+}
+
+type Scopes interface {
+	Names() []string
+	Inspector(scope string) (limiter.Inspector, bool)
+}
+
+type server struct {
+	keys   limiter.Inspector
+	cfg    Config
+	tokens map[string][sha256.Size]byte
+}
+
+func NewHandler(keys limiter.Inspector, cfg Config) http.Handler {
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+
+	s := &server{keys: keys, cfg: cfg, tokens: make(map[string][sha256.Size]byte, len(cfg.Tokens))}
+	for actor, token := range cfg.Tokens {
+		s.tokens[actor] = sha256.Sum256([]byte(token))
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/keys", s.topKeys)
+	mux.HandleFunc("GET /admin/keys/{key...}", s.inspect)
+	mux.HandleFunc("DELETE /admin/keys/{key...}", s.reset)
+
+	if cfg.Overrides != nil {
+		mux.HandleFunc("GET /admin/overrides", s.listOverrides)
+		mux.HandleFunc("GET /admin/overrides/{key...}", s.getOverride)
+		mux.HandleFunc("PUT /admin/overrides/{key...}", s.putOverride)
+		mux.HandleFunc("DELETE /admin/overrides/{key...}", s.deleteOverride)
+		mux.HandleFunc("GET /admin/audit", s.audit)
+	}
+
+	if cfg.APIKeys != nil {
+		mux.HandleFunc("GET /admin/apikeys", s.listAPIKeys)
+		mux.HandleFunc("POST /admin/apikeys", s.issueAPIKey)
+		mux.HandleFunc("DELETE /admin/apikeys/{hash}", s.revokeAPIKey)
+	}
+
+	return s.authenticate(mux)
+}
+
+type actorKey struct{}
+
+func actorFrom(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// Hashes are compared so the time taken does not depend on the length of the
+// presented token, and every token is compared so it does not reveal which
+// one matched.
+func (s *server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		presented, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		sum := sha256.Sum256([]byte(presented))
+
+		actor := ""
+		for name, token := range s.tokens {
+			if subtle.ConstantTimeCompare(sum[:], token[:]) == 1 {
+				actor = name
+			}
+		}
+
+		if !ok || actor == "" {
+			s.cfg.Logger.Warn("admin request rejected", "method", r.Method, "path", r.URL.Path, "remote_addr", r.RemoteAddr)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			writeProblem(w, r, http.StatusUnauthorized, "missing or invalid admin token")
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), actorKey{}, actor)))
+	})
+}
+
+func (s *server) topKeys(w http.ResponseWriter, r *http.Request) {
+	n := defaultTopKeys
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 1 || v > maxTopKeys {
+			writeProblem(w, r, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(maxTopKeys))
+			return
+		}
+		n = v
+	}
+
+	scopes := []string{r.URL.Query().Get("scope")}
+	if scopes[0] == "" && s.cfg.Scopes != nil {
+		scopes = append(scopes, s.cfg.Scopes.Names()...)
+	}
+
+	type scoped struct {
+		scope string
+		state limiter.KeyState
+	}
+	var all []scoped
+	for _, scope := range scopes {
+		keys, ok := s.inspector(w, r, scope)
+		if !ok {
+			return
+		}
+
+		states, err := keys.TopKeys(r.Context(), n, nil)
+		if err != nil {
+			s.fail(w, r, err)
+			return
+		}
+		for _, state := range states {
+			all = append(all, scoped{scope, state})
+		}
+	}
+
+	sort.SliceStable(all, func(i, j int) bool {
+		return limiter.Usage(all[i].state) > limiter.Usage(all[j].state)
+	})
+	views := make([]keyView, 0, min(len(all), n))
+	for _, sc := range all[:min(len(all), n)] {
+		v := newKeyView(sc.state)
+		v.Scope = sc.scope
+		views = append(views, v)
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Strategy string    `json:"strategy"`
+		Keys     []keyView `json:"keys"`
+	}{s.cfg.Strategy, views})
+}
+
+func (s *server) inspector(w http.ResponseWriter, r *http.Request, scope string) (limiter.Inspector, bool) {
+	if scope == "" {
+		return s.keys, true
+	}
+
+	if s.cfg.Scopes != nil {
+		if keys, ok := s.cfg.Scopes.Inspector(scope); ok {
+			return keys, true
+		}
+	}
+
+	writeProblem(w, r, http.StatusNotFound, "no limiter has the scope "+strconv.Quote(scope))
+	return nil, false
+}
+
+func (s *server) inspect(w http.ResponseWriter, r *http.Request) {
+	scope := r.URL.Query().Get("scope")
+	keys, ok := s.inspector(w, r, scope)
+	if !ok {
+		return
+	}
+
+	state, err := keys.Inspect(r.Context(), clientkey.Normalize(r.PathValue("key")))
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	v := newKeyView(state)
+	v.Scope = scope
+	writeJSON(w, http.StatusOK, struct {
+		Strategy string `json:"strategy"`
+		keyView
+	}{s.cfg.Strategy, v})
+}
+
+func (s *server) reset(w http.ResponseWriter, r *http.Request) {
+	scope := r.URL.Query().Get("scope")
+	keys, ok := s.inspector(w, r, scope)
+	if !ok {
+		return
+	}
+
+	key := clientkey.Normalize(r.PathValue("key"))
+	if err := keys.Reset(r.Context(), key); err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	s.cfg.Logger.Info("admin reset key", "actor", actorFrom(r.Context()), "api_key", clientkey.Mask(key), "scope", scope, "remote_addr", r.RemoteAddr)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) fail(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, limiter.ErrNotInspectable) {
+		writeProblem(w, r, http.StatusNotImplemented, "the active strategy cannot be inspected")
+		return
+	}
+
+	s.cfg.Logger.Error("admin request failed", "method", r.Method, "path", r.URL.Path, "error", err)
+	writeProblem(w, r, http.StatusServiceUnavailable, "limiter state is unavailable")
+}
+
+type keyView struct {
+	Key           string      `json:"key"`
+	Scope         string      `json:"scope,omitempty"`
+	Limit         int         `json:"limit"`
+	WindowSeconds float64     `json:"window_seconds"`
+	Used          float64     `json:"used"`
+	Remaining     int         `json:"remaining"`
+	ResetAt       time.Time   `json:"reset_at"`
+	Tokens        *float64    `json:"tokens,omitempty"`
+	Timestamps    []time.Time `json:"timestamps,omitempty"`
+	TAT           *time.Time  `json:"tat,omitempty"`
+	Windows       []keyView   `json:"windows,omitempty"`
+}
+
+func newKeyView(state limiter.KeyState) keyView {
+	v := keyView{
+		Key:           state.Key,
+		Limit:         state.Limit,
+		WindowSeconds: state.Window.Seconds(),
+		Used:          state.Used,
+		Remaining:     state.Remaining,
+		ResetAt:       state.ResetAt,
+		Tokens:        state.Tokens,
+		Timestamps:    state.Timestamps,
+		TAT:           state.TAT,
+	}
+
+	for _, w := range state.Windows {
+		v.Windows = append(v.Windows, newKeyView(w))
+	}
+
+	return v
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"type":     "about:blank",
+		"title":    http.StatusText(status),
+		"status":   status,
+		"detail":   detail,
+		"instance": r.URL.Path,
+	})
+}
@@ -0,0 +1,168 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/bellettati/go-rate-limited-api/internal/apikeys"
+	"github.com/bellettati/go-rate-limited-api/internal/limiter"
+	"github.com/bellettati/go-rate-limited-api/internal/overrides"
+)
+
+func newTestAdmin(t *testing.T) (http.Handler, *limiter.TokenBucketLimiter) {
+	t.Helper()
+
+	rl := limiter.NewTokenBucketLimiter(limiter.NewFakeClock(time.Now()), limiter.LimitConfig{Limit: 10, Window: time.Minute}, nil)
+	t.Cleanup(func() { _ = rl.Close() })
+
+	return NewHandler(rl, Config{
+		Tokens:    map[string]string{"alice": "s3cret", "bob": "hunter2"},
+		Overrides: overrides.NewManager(overrides.NewMemoryStore(), overrides.ManagerConfig{}),
+		APIKeys:   apikeys.NewRegistry(apikeys.NewMemoryStore(), apikeys.RegistryConfig{}),
+		Strategy:  "token_bucket",
+	}), rl
+}
+
+func do(h http.Handler, method, target, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAdminRequiresToken(t *testing.T) {
+	h, _ := newTestAdmin(t)
+
+	for _, token := range []string{"", "wrong"} {
+		rec := do(h, http.MethodGet, "/admin/keys", token)
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("expected 401 for token %q, got %d", token, rec.Code)
+		}
+		if rec.Header().Get("WWW-Authenticate") == "" {
+			t.Fatalf("expected a WWW-Authenticate challenge")
+		}
+	}
+}
+
+func TestAdminInspectsListsAndResetsKeys(t *testing.T) {
+	h, rl := newTestAdmin(t)
+	ctx := context.Background()
+
+	// The middleware tags API keys; the admin API takes them untagged too.
+	busy := "key:abc"
+	rl.AllowN(ctx, busy, 7)
+	rl.AllowN(ctx, "key:quiet", 1)
+
+	rec := do(h, http.MethodGet, "/admin/keys?limit=1", "s3cret")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	var top struct {
+		Strategy string    `json:"strategy"`
+		Keys     []keyView `json:"keys"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &top); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if top.Strategy != "token_bucket" || len(top.Keys) != 1 || top.Keys[0].Key != busy {
+		t.Fatalf("expected the busy key alone, got %+v", top)
+	}
+
+	target := "/admin/keys/abc"
+	rec = do(h, http.MethodGet, target, "s3cret")
+
+	var state keyView
+	if err := json.Unmarshal(rec.Body.Bytes(), &state); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if state.Key != busy || state.Remaining != 3 || state.Tokens == nil || *state.Tokens != 3 {
+		t.Fatalf("expected 3 tokens left for %q, got %+v", busy, state)
+	}
+
+	if rec := do(h, http.MethodDelete, target, "s3cret"); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", rec.Code)
+	}
+	if state, _ := rl.Inspect(ctx, busy); state.Used != 0 {
+		t.Fatalf("expected the key to be reset, got %+v", state)
+	}
+}
+
+type scopeMap map[string]limiter.Inspector
+
+func (m scopeMap) Names() []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	return names
+}
+
+func (m scopeMap) Inspector(scope string) (limiter.Inspector, bool) {
+	l, ok := m[scope]
+	return l, ok
+}
+
+func TestAdminKeepsScopesApart(t *testing.T) {
+	clock := limiter.NewFakeClock(time.Now())
+	def := limiter.NewTokenBucketLimiter(clock, limiter.LimitConfig{Limit: 10, Window: time.Minute}, nil)
+	orders := limiter.NewTokenBucketLimiter(clock, limiter.LimitConfig{Limit: 10, Window: time.Minute}, nil)
+	t.Cleanup(func() {
+		_ = def.Close()
+		_ = orders.Close()
+	})
+
+	h := NewHandler(def, Config{
+		Tokens: map[string]string{"alice": "s3cret"},
+		Scopes: scopeMap{"route:POST /orders": orders},
+	})
+	ctx := context.Background()
+
+	orders.AllowN(ctx, "key:abc", 7)
+	def.AllowN(ctx, "key:abc", 2)
+
+	rec := do(h, http.MethodGet, "/admin/keys", "s3cret")
+	var top struct {
+		Keys []keyView `json:"keys"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &top); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(top.Keys) != 2 ||
+		top.Keys[0].Key != "key:abc" || top.Keys[0].Scope != "route:POST /orders" ||
+		top.Keys[1].Key != "key:abc" || top.Keys[1].Scope != "" {
+		t.Fatalf("expected the key listed once per scope, got %+v", top.Keys)
+	}
+
+	scope := "?scope=" + url.QueryEscape("route:POST /orders")
+	if rec := do(h, http.MethodDelete, "/admin/keys/abc"+scope, "s3cret"); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", rec.Code)
+	}
+	if state, _ := orders.Inspect(ctx, "key:abc"); state.Used != 0 {
+		t.Fatalf("expected the key to be reset in the route scope, got %+v", state)
+	}
+	if state, _ := def.Inspect(ctx, "key:abc"); state.Used != 2 {
+		t.Fatalf("expected the default scope to be left alone, got %+v", state)
+	}
+
+	if rec := do(h, http.MethodGet, "/admin/keys/abc?scope=route:GET%20/nope", "s3cret"); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown scope, got %d", rec.Code)
+	}
+}
+
+func TestAdminRejectsBadLimit(t *testing.T) {
+	h, _ := newTestAdmin(t)
+
+	if rec := do(h, http.MethodGet, "/admin/keys?limit=0", "s3cret"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,74 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/bellettati/go-rate-limited-api/internal/apikeys"
+)
+
+type issueRequest struct {
+	Owner string `json:"owner"`
+	Tier  string `json:"tier"`
+}
+
+func (s *server) listAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := s.cfg.APIKeys.List(r.Context())
+	if err != nil {
+		s.apiKeysFailed(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Keys []apikeys.Key `json:"keys"`
+	}{keys})
+}
+
+// issueAPIKey answers with the new key. It is not stored, so this response
+// is the only place it appears.
+func (s *server) issueAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req issueRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return
+	}
+
+	apiKey, k, err := s.cfg.APIKeys.Issue(r.Context(), req.Owner, req.Tier)
+	if errors.Is(err, apikeys.ErrInvalid) {
+		writeProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		s.apiKeysFailed(w, r, err)
+		return
+	}
+
+	s.cfg.Logger.Info("admin issued API key", "actor", actorFrom(r.Context()), "owner", k.Owner, "tier", k.Tier, "hash", k.Hash)
+	writeJSON(w, http.StatusCreated, struct {
+		APIKey string `json:"api_key"`
+		apikeys.Key
+	}{apiKey, k})
+}
+
+func (s *server) revokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	k, err := s.cfg.APIKeys.Revoke(r.Context(), r.PathValue("hash"))
+	if errors.Is(err, apikeys.ErrUnknownKey) {
+		writeProblem(w, r, http.StatusNotFound, "no API key is registered under this hash")
+		return
+	}
+	if err != nil {
+		s.apiKeysFailed(w, r, err)
+		return
+	}
+
+	s.cfg.Logger.Info("admin revoked API key", "actor", actorFrom(r.Context()), "owner", k.Owner, "hash", k.Hash)
+	writeJSON(w, http.StatusOK, k)
+}
+
+func (s *server) apiKeysFailed(w http.ResponseWriter, r *http.Request, err error) {
+	s.cfg.Logger.Error("admin request failed", "method", r.Method, "path", r.URL.Path, "error", err)
+	writeProblem(w, r, http.StatusServiceUnavailable, "API key registry is unavailable")
+}
@@ -0,0 +1,48 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/bellettati/go-rate-limited-api/internal/apikeys"
+)
+
+func TestAdminIssuesListsAndRevokesAPIKeys(t *testing.T) {
+	h, _ := newTestAdmin(t)
+
+	rec := doJSON(h, http.MethodPost, "/admin/apikeys", "s3cret", `{"owner": "acme", "tier": "partner"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+
+	var issued struct {
+		APIKey string `json:"api_key"`
+		apikeys.Key
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &issued); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if issued.APIKey == "" || issued.Hash != apikeys.Hash(issued.APIKey) || issued.Status != apikeys.StatusActive {
+		t.Fatalf("expected a new active key and its hash, got %+v", issued)
+	}
+
+	rec = do(h, http.MethodGet, "/admin/apikeys", "s3cret")
+	if strings.Contains(rec.Body.String(), issued.APIKey) || !strings.Contains(rec.Body.String(), issued.Hash) {
+		t.Fatalf("expected the list to show the hash but never the key, got %s", rec.Body)
+	}
+
+	rec = do(h, http.MethodDelete, "/admin/apikeys/"+issued.Hash, "s3cret")
+	var revoked apikeys.Key
+	if err := json.Unmarshal(rec.Body.Bytes(), &revoked); err != nil || revoked.Status != apikeys.StatusRevoked {
+		t.Fatalf("expected the key to be revoked, got %d: %s", rec.Code, rec.Body)
+	}
+
+	if rec := do(h, http.MethodDelete, "/admin/apikeys/"+apikeys.Hash("never issued"), "s3cret"); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown hash, got %d", rec.Code)
+	}
+	if rec := doJSON(h, http.MethodPost, "/admin/apikeys", "s3cret", `{"tier": "partner"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without an owner, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,142 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bellettati/go-rate-limited-api/internal/clientkey"
+	"github.com/bellettati/go-rate-limited-api/internal/config"
+	"github.com/bellettati/go-rate-limited-api/internal/overrides"
+)
+
+type overrideRequest struct {
+	Tier        string             `json:"tier"`
+	Limits      []config.LimitJSON `json:"limits"`
+	Concurrency int                `json:"concurrency"`
+	ExpiresAt   *time.Time         `json:"expires_at"`
+	// TTL is a Go duration, e.g. "72h".
+	TTL    string `json:"ttl"`
+	Reason string `json:"reason"`
+}
+
+func (s *server) listOverrides(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, struct {
+		Overrides []overrides.Override `json:"overrides"`
+	}{s.cfg.Overrides.List()})
+}
+
+func (s *server) getOverride(w http.ResponseWriter, r *http.Request) {
+	o, ok := s.cfg.Overrides.Get(clientkey.Normalize(r.PathValue("key")))
+	if !ok {
+		writeProblem(w, r, http.StatusNotFound, "the key has no override")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, o)
+}
+
+func (s *server) putOverride(w http.ResponseWriter, r *http.Request) {
+	key := clientkey.Normalize(r.PathValue("key"))
+
+	var req overrideRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return
+	}
+
+	concurrency, err := config.ParseConcurrency("override", req.Concurrency)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	o := overrides.Override{Key: key, Policy: config.KeyPolicy{Tier: req.Tier, Concurrency: concurrency}, Reason: req.Reason}
+	if len(req.Limits) > 0 {
+		limits, err := config.ParseLimits("limits", req.Limits)
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		o.Policy.Limits = limits
+	}
+
+	switch {
+	case req.ExpiresAt != nil && req.TTL != "":
+		writeProblem(w, r, http.StatusBadRequest, "set expires_at or ttl, not both")
+		return
+	case req.ExpiresAt != nil:
+		o.ExpiresAt = *req.ExpiresAt
+	case req.TTL != "":
+		ttl, err := time.ParseDuration(req.TTL)
+		if err != nil || ttl <= 0 {
+			writeProblem(w, r, http.StatusBadRequest, "ttl must be a positive duration, e.g. \"72h\"")
+			return
+		}
+		o.ExpiresAt = time.Now().Add(ttl)
+	}
+
+	saved, created, err := s.cfg.Overrides.Put(r.Context(), actorFrom(r.Context()), o)
+	if errors.Is(err, overrides.ErrInvalid) {
+		writeProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		s.overridesFailed(w, r, err)
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	writeJSON(w, status, saved)
+}
+
+func (s *server) deleteOverride(w http.ResponseWriter, r *http.Request) {
+	err := s.cfg.Overrides.Delete(r.Context(), actorFrom(r.Context()), clientkey.Normalize(r.PathValue("key")))
+	if errors.Is(err, overrides.ErrNotFound) {
+		writeProblem(w, r, http.StatusNotFound, "the key has no override")
+		return
+	}
+	if err != nil {
+		s.overridesFailed(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) audit(w http.ResponseWriter, r *http.Request) {
+	n := defaultAuditEntries
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 1 || v > maxAuditEntries {
+			writeProblem(w, r, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(maxAuditEntries))
+			return
+		}
+		n = v
+	}
+
+	entries, err := s.cfg.Overrides.Audit(r.Context(), n)
+	if err != nil {
+		s.overridesFailed(w, r, err)
+		return
+	}
+	if entries == nil {
+		entries = []overrides.AuditEntry{}
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Entries []overrides.AuditEntry `json:"entries"`
+	}{entries})
+}
+
+func (s *server) overridesFailed(w http.ResponseWriter, r *http.Request, err error) {
+	s.cfg.Logger.Error("admin request failed", "method", r.Method, "path", r.URL.Path, "error", err)
+	writeProblem(w, r, http.StatusServiceUnavailable, "override store is unavailable")
+}
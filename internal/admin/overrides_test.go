@@ -0,0 +1,91 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bellettati/go-rate-limited-api/internal/overrides"
+)
+
+func doJSON(h http.Handler, method, target, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAdminManagesOverridesAndAuditsWho(t *testing.T) {
+	h, _ := newTestAdmin(t)
+
+	rec := doJSON(h, http.MethodPut, "/admin/overrides/acme", "s3cret", `{"limits": [{"limit": 500, "window": "1m"}], "ttl": "48h", "reason": "migration"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+
+	var created overrides.Override
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if created.CreatedBy != "alice" || len(created.Policy.Limits) != 1 || created.Policy.Limits[0].Limit != 500 {
+		t.Fatalf("expected alice's 500/min override, got %+v", created)
+	}
+	if left := time.Until(created.ExpiresAt); left < 47*time.Hour || left > 48*time.Hour {
+		t.Fatalf("expected expiry in 48h, got %s", created.ExpiresAt)
+	}
+
+	rec = doJSON(h, http.MethodPut, "/admin/overrides/acme", "hunter2", `{"tier": "partner"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 on update, got %d: %s", rec.Code, rec.Body)
+	}
+
+	if rec := do(h, http.MethodDelete, "/admin/overrides/acme", "hunter2"); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", rec.Code)
+	}
+	if rec := do(h, http.MethodGet, "/admin/overrides/acme", "s3cret"); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 after delete, got %d", rec.Code)
+	}
+
+	rec = do(h, http.MethodGet, "/admin/audit", "s3cret")
+	var audit struct {
+		Entries []overrides.AuditEntry `json:"entries"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &audit); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+
+	var got []string
+	for _, e := range audit.Entries {
+		got = append(got, e.Actor+" "+e.Action)
+	}
+	if want := "bob delete,bob update,alice create"; strings.Join(got, ",") != want {
+		t.Fatalf("expected audit %q, got %q", want, got)
+	}
+}
+
+func TestAdminRejectsInvalidOverrides(t *testing.T) {
+	h, _ := newTestAdmin(t)
+
+	for _, body := range []string{
+		`{}`,
+		`{"tier": "partner", "limits": [{"limit": 1, "window": "1s"}]}`,
+		`{"limits": [{"limit": 0, "window": "1s"}]}`,
+		`{"tier": "partner", "ttl": "-1h"}`,
+		`{"tier": "partner", "expires_at": "2001-01-01T00:00:00Z"}`,
+		`{"tier": "partner", "ttl": "1h", "expires_at": "2999-01-01T00:00:00Z"}`,
+		`{"tier": "partner", "unknown": true}`,
+	} {
+		if rec := doJSON(h, http.MethodPut, "/admin/overrides/acme", "s3cret", body); rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %s, got %d: %s", body, rec.Code, rec.Body)
+		}
+	}
+
+	if rec := do(h, http.MethodDelete, "/admin/overrides/acme", "s3cret"); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a key without an override, got %d", rec.Code)
+	}
+}
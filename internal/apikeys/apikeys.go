@@ -0,0 +1,216 @@
+// Package apikeys is the registry of issued API keys, stored by SHA-256 hash.
+package apikeys
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusActive  Status = "active"
+	StatusRevoked Status = "revoked"
+)
+
+type Key struct {
+	Hash      string    `json:"hash"`
+	Owner     string    `json:"owner"`
+	Tier      string    `json:"tier,omitempty"`
+	Status    Status    `json:"status"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+}
+
+func (k Key) validate() error {
+	if b, err := hex.DecodeString(k.Hash); err != nil || len(b) != sha256.Size {
+		return errors.New("hash must be a hex encoded SHA-256")
+	}
+	if k.Owner == "" {
+		return errors.New("owner must not be empty")
+	}
+	if k.Status != StatusActive && k.Status != StatusRevoked {
+		return fmt.Errorf("status must be %s or %s", StatusActive, StatusRevoked)
+	}
+
+	return nil
+}
+
+func Hash(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
+
+func Generate() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return "rl_" + hex.EncodeToString(b), nil
+}
+
+var (
+	ErrUnknownKey = errors.New("unknown API key")
+	ErrInvalid    = errors.New("invalid API key entry")
+)
+
+type Store interface {
+	Get(ctx context.Context, hash string) (Key, error)
+	Put(ctx context.Context, k Key) error
+	// Add saves k only if its hash is not registered yet.
+	Add(ctx context.Context, k Key) (bool, error)
+	List(ctx context.Context) ([]Key, error)
+}
+
+// The cache is emptied rather than grown when full, since clients minting
+// fresh keys fill it with unknown ones.
+const maxCachedKeys = 10000
+
+type cachedKey struct {
+	key     Key
+	err     error
+	expires time.Time
+}
+
+type Registry struct {
+	st       Store
+	ttl      time.Duration
+	now      func() time.Time
+	validate func(Key) error
+
+	mu    sync.Mutex
+	cache map[string]cachedKey
+}
+
+type RegistryConfig struct {
+	CacheTTL time.Duration
+	Validate func(Key) error
+	Now      func() time.Time
+}
+
+func NewRegistry(st Store, cfg RegistryConfig) *Registry {
+	if cfg.Validate == nil {
+		cfg.Validate = func(Key) error { return nil }
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+
+	return &Registry{
+		st:       st,
+		ttl:      cfg.CacheTTL,
+		now:      cfg.Now,
+		validate: cfg.Validate,
+		cache:    make(map[string]cachedKey),
+	}
+}
+
+// Revoked keys are returned with their status, not ErrUnknownKey.
+func (r *Registry) Lookup(ctx context.Context, apiKey string) (Key, error) {
+	hash := Hash(apiKey)
+	now := r.now()
+
+	r.mu.Lock()
+	c, ok := r.cache[hash]
+	r.mu.Unlock()
+	if ok && now.Before(c.expires) {
+		return c.key, c.err
+	}
+
+	k, err := r.st.Get(ctx, hash)
+	if err != nil && !errors.Is(err, ErrUnknownKey) {
+		return Key{}, err
+	}
+
+	if r.ttl > 0 {
+		r.mu.Lock()
+		if len(r.cache) >= maxCachedKeys {
+			clear(r.cache)
+		}
+		r.cache[hash] = cachedKey{key: k, err: err, expires: now.Add(r.ttl)}
+		r.mu.Unlock()
+	}
+
+	return k, err
+}
+
+func (r *Registry) Put(ctx context.Context, k Key) error {
+	if err := k.validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if err := r.validate(k); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	if err := r.st.Put(ctx, k); err != nil {
+		return err
+	}
+	r.forget(k.Hash)
+
+	return nil
+}
+
+// Add leaves an entry that was revoked or changed since alone.
+func (r *Registry) Add(ctx context.Context, k Key) (bool, error) {
+	if err := k.validate(); err != nil {
+		return false, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if err := r.validate(k); err != nil {
+		return false, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	added, err := r.st.Add(ctx, k)
+	if err != nil {
+		return false, err
+	}
+	r.forget(k.Hash)
+
+	return added, nil
+}
+
+func (r *Registry) Issue(ctx context.Context, owner, tier string) (string, Key, error) {
+	apiKey, err := Generate()
+	if err != nil {
+		return "", Key{}, err
+	}
+
+	k := Key{Hash: Hash(apiKey), Owner: owner, Tier: tier, Status: StatusActive, CreatedAt: r.now()}
+	if err := r.Put(ctx, k); err != nil {
+		return "", Key{}, err
+	}
+
+	return apiKey, k, nil
+}
+
+// Revoked keys stay in the registry, so their requests are told why they are
+// refused.
+func (r *Registry) Revoke(ctx context.Context, hash string) (Key, error) {
+	k, err := r.st.Get(ctx, hash)
+	if err != nil {
+		return Key{}, err
+	}
+
+	k.Status = StatusRevoked
+	if err := r.st.Put(ctx, k); err != nil {
+		return Key{}, err
+	}
+	r.forget(hash)
+
+	return k, nil
+}
+
+func (r *Registry) List(ctx context.Context) ([]Key, error) {
+	return r.st.List(ctx)
+}
+
+func (r *Registry) forget(hash string) {
+	r.mu.Lock()
+	delete(r.cache, hash)
+	r.mu.Unlock()
+}
@@ -0,0 +1,83 @@
+package apikeys
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type countingStore struct {
+	*MemoryStore
+	gets int
+}
+
+func (s *countingStore) Get(ctx context.Context, hash string) (Key, error) {
+	s.gets++
+	return s.MemoryStore.Get(ctx, hash)
+}
+
+func TestRegistryLooksUpIssuedAndRevokedKeys(t *testing.T) {
+	ctx := context.Background()
+	st := &countingStore{MemoryStore: NewMemoryStore()}
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	reg := NewRegistry(st, RegistryConfig{CacheTTL: time.Minute, Now: func() time.Time { return now }})
+
+	apiKey, issued, err := reg.Issue(ctx, "acme", "partner")
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+	if issued.Hash != Hash(apiKey) || strings.Contains(issued.Hash, apiKey) {
+		t.Fatalf("expected only the hash to be stored, got %+v", issued)
+	}
+
+	for range 3 {
+		k, err := reg.Lookup(ctx, apiKey)
+		if err != nil || k.Owner != "acme" || k.Tier != "partner" || k.Status != StatusActive {
+			t.Fatalf("expected acme's active partner key, got %+v, %v", k, err)
+		}
+	}
+	if st.gets != 1 {
+		t.Fatalf("expected repeated lookups to be cached, got %d store reads", st.gets)
+	}
+
+	if _, err := reg.Lookup(ctx, "rl_made_up"); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("expected ErrUnknownKey, got %v", err)
+	}
+
+	if _, err := reg.Revoke(ctx, issued.Hash); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if k, _ := reg.Lookup(ctx, apiKey); k.Status != StatusRevoked {
+		t.Fatalf("expected the revocation to bypass the cache, got %+v", k)
+	}
+
+	if _, err := reg.Revoke(ctx, Hash("rl_made_up")); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("expected ErrUnknownKey revoking an unknown key, got %v", err)
+	}
+}
+
+func TestParseValidatesEntries(t *testing.T) {
+	hash := Hash("secret")
+
+	keys, err := Parse(strings.NewReader(`{"keys": [{"hash": "` + strings.ToUpper(hash) + `", "owner": "acme", "tier": "partner"}]}`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(keys) != 1 || keys[0].Hash != hash || keys[0].Status != StatusActive {
+		t.Fatalf("expected one active key, got %+v", keys)
+	}
+
+	for _, raw := range []string{
+		`{"keys": [{"hash": "secret", "owner": "acme"}]}`,
+		`{"keys": [{"hash": "` + hash + `"}]}`,
+		`{"keys": [{"hash": "` + hash + `", "owner": "acme", "status": "paused"}]}`,
+		`{"keys": [{"hash": "` + hash + `", "owner": "a"}, {"hash": "` + hash + `", "owner": "b"}]}`,
+		`{"keys": [{"key": "secret", "owner": "acme"}]}`,
+	} {
+		if _, err := Parse(strings.NewReader(raw)); err == nil {
+			t.Fatalf("expected %s to be rejected", raw)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package apikeys
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+type keysFile struct {
+	Keys []Key `json:"keys"`
+}
+
+// The file looks like
+//
+//	{"keys": [{"hash": "9f86d0…", "owner": "acme", "tier": "partner"}]}
+func LoadFile(path string) ([]Key, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("API keys file: %w", err)
+	}
+	defer f.Close()
+
+	keys, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("API keys file %s: %w", path, err)
+	}
+
+	return keys, nil
+}
+
+func Parse(r io.Reader) ([]Key, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+
+	var raw keysFile
+	if err := dec.Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(raw.Keys))
+	for i := range raw.Keys {
+		k := &raw.Keys[i]
+		k.Hash = strings.ToLower(k.Hash)
+		if k.Status == "" {
+			k.Status = StatusActive
+		}
+
+		if err := k.validate(); err != nil {
+			return nil, fmt.Errorf("keys[%d]: %w", i, err)
+		}
+		if seen[k.Hash] {
+			return nil, fmt.Errorf("keys[%d]: hash is listed twice", i)
+		}
+		seen[k.Hash] = true
+	}
+
+	return raw.Keys, nil
+}
@@ -0,0 +1,70 @@
+package apikeys
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+type MemoryStore struct {
+	mu   sync.Mutex
+	keys map[string]Key
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{keys: make(map[string]Key)}
+}
+
+func (s *MemoryStore) Get(_ context.Context, hash string) (Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.keys[hash]
+	if !ok {
+		return Key{}, ErrUnknownKey
+	}
+
+	return k, nil
+}
+
+func (s *MemoryStore) Put(_ context.Context, k Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[k.Hash] = k
+	return nil
+}
+
+func (s *MemoryStore) Add(_ context.Context, k Key) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.keys[k.Hash]; ok {
+		return false, nil
+	}
+
+	s.keys[k.Hash] = k
+	return true, nil
+}
+
+func (s *MemoryStore) List(_ context.Context) ([]Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]Key, 0, len(s.keys))
+	for _, k := range s.keys {
+		keys = append(keys, k)
+	}
+	sortKeys(keys)
+
+	return keys, nil
+}
+
+func sortKeys(keys []Key) {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Owner != keys[j].Owner {
+			return keys[i].Owner < keys[j].Owner
+		}
+		return keys[i].Hash < keys[j].Hash
+	})
+}
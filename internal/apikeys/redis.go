@@ -0,0 +1,74 @@
+package apikeys
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const redisKeysKey = "rl:apikeys"
+
+type RedisStore struct {
+	client redis.UniversalClient
+}
+
+func NewRedisStore(client redis.UniversalClient) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func (s *RedisStore) Get(ctx context.Context, hash string) (Key, error) {
+	data, err := s.client.HGet(ctx, redisKeysKey, hash).Result()
+	if errors.Is(err, redis.Nil) {
+		return Key{}, ErrUnknownKey
+	}
+	if err != nil {
+		return Key{}, err
+	}
+
+	var k Key
+	if err := json.Unmarshal([]byte(data), &k); err != nil {
+		return Key{}, fmt.Errorf("API key %s: %w", hash, err)
+	}
+
+	return k, nil
+}
+
+func (s *RedisStore) Put(ctx context.Context, k Key) error {
+	data, err := json.Marshal(k)
+	if err != nil {
+		return err
+	}
+
+	return s.client.HSet(ctx, redisKeysKey, k.Hash, data).Err()
+}
+
+func (s *RedisStore) Add(ctx context.Context, k Key) (bool, error) {
+	data, err := json.Marshal(k)
+	if err != nil {
+		return false, err
+	}
+
+	return s.client.HSetNX(ctx, redisKeysKey, k.Hash, data).Result()
+}
+
+func (s *RedisStore) List(ctx context.Context) ([]Key, error) {
+	raw, err := s.client.HGetAll(ctx, redisKeysKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]Key, 0, len(raw))
+	for hash, data := range raw {
+		var k Key
+		if err := json.Unmarshal([]byte(data), &k); err != nil {
+			return nil, fmt.Errorf("API key %s: %w", hash, err)
+		}
+		keys = append(keys, k)
+	}
+	sortKeys(keys)
+
+	return keys, nil
+}
@@ -0,0 +1,36 @@
+// Package clientkey spells the keys clients are counted against.
+package clientkey
+
+import "strings"
+
+var Tags = []string{"key:", "header:", "query:", "sub:", "ip:", "route:"}
+
+// Keys without a tag, e.g. in the policy file or the admin API, are taken to
+// be API keys.
+func Normalize(key string) string {
+	for _, tag := range Tags {
+		if strings.HasPrefix(key, tag) {
+			return key
+		}
+	}
+
+	return "key:" + key
+}
+
+func Mask(key string) string {
+	for _, tag := range Tags {
+		if rest, ok := strings.CutPrefix(key, tag); ok {
+			return tag + maskValue(rest)
+		}
+	}
+
+	return maskValue(key)
+}
+
+func maskValue(key string) string {
+	if len(key) <= 4 {
+		return "****"
+	}
+
+	return key[:2] + "****" + key[len(key)-2:]
+}
@@ -0,0 +1,28 @@
+package clientkey
+
+import "testing"
+
+func TestNormalizeTagsUntaggedKeysAsAPIKeys(t *testing.T) {
+	for raw, want := range map[string]string{
+		"acme":           "key:acme",
+		"key:acme":       "key:acme",
+		"ip:203.0.113.5": "ip:203.0.113.5",
+	} {
+		if got := Normalize(raw); got != want {
+			t.Fatalf("expected %q to normalize to %q, got %q", raw, want, got)
+		}
+	}
+}
+
+func TestMaskKeepsTheTag(t *testing.T) {
+	for raw, want := range map[string]string{
+		"rl_secret":      "rl****et",
+		"key:rl_secret":  "key:rl****et",
+		"ip:203.0.113.5": "ip:20****.5",
+		"key:abc":        "key:****",
+	} {
+		if got := Mask(raw); got != want {
+			t.Fatalf("expected %q to mask to %q, got %q", raw, want, got)
+		}
+	}
+}
@@ -1,7 +1,10 @@
 package config
 
 import (
+	"fmt"
 	"log"
+	"log/slog"
+	"net/netip"
 	"os"
 	"strconv"
 	"strings"
@@ -16,6 +19,8 @@ const (
 	FixedWindow RateLimitStrategy = "fixed_window"
 	SlidingWindow RateLimitStrategy = "sliding_window"
 	TokenBucket RateLimitStrategy = "token_bucket"
+	GCRA RateLimitStrategy = "gcra"
+	LeakyBucket RateLimitStrategy = "leaky_bucket"
 )
 
 type RateLimitBackend string
@@ -25,12 +30,83 @@ const (
 	Redis RateLimitBackend = "redis"
 )
 
+type FailureMode string
+
+const (
+	FailOpen FailureMode = "open"
+	FailClosed FailureMode = "closed"
+)
+
+type HeaderStyle string
+
+const (
+	HeadersLegacy HeaderStyle = "legacy"
+	HeadersIETF HeaderStyle = "ietf"
+	HeadersBoth HeaderStyle = "both"
+)
+
+type LogFormat string
+
+const (
+	LogText LogFormat = "text"
+	LogJSON LogFormat = "json"
+)
+
+type LimitWindow struct {
+	Limit int
+	Window time.Duration
+}
+
 type Config struct {
 	RateLimitStrategy RateLimitStrategy 
 	RateLimitBackend RateLimitBackend
 
+	RateLimitFailureMode FailureMode
+	RateLimitFailureStatus int
+
+	RateLimitHeaders HeaderStyle
+
 	DefaultLimit      int
 	DefaultWindow     time.Duration
+	ExtraLimits []LimitWindow
+
+	ClientKey [][]KeyPart
+	TrustedProxies []netip.Prefix
+	IPAllowlist []netip.Prefix
+	IPDenylist []netip.Prefix
+
+	PolicyFile string
+	Policy *Policy
+	PolicyReloadInterval time.Duration
+
+	QueueDepth int
+	QueueMaxWait time.Duration
+
+	CleanupInterval time.Duration
+
+	MaxConcurrentRequests int
+	ConcurrencyLeaseTTL time.Duration
+
+	LogFormat LogFormat
+	LogLevel slog.Level
+
+	ListenAddr string
+	HTTPReadTimeout time.Duration
+	HTTPReadHeaderTimeout time.Duration
+	HTTPWriteTimeout time.Duration
+	HTTPIdleTimeout time.Duration
+	ShutdownTimeout time.Duration
+
+	MetricsAddr string
+
+	AdminAddr string
+	AdminTokens map[string]string
+	OverrideSyncInterval time.Duration
+
+	// Empty accepts any API key.
+	APIKeyRegistry RateLimitBackend
+	APIKeysFile string
+	APIKeyCacheTTL time.Duration
 
 	RedisAddr string
 	RedisPassword string
@@ -38,6 +114,11 @@ type Config struct {
 	RedisDialTimeout time.Duration
 	RedisReadTimeout time.Duration
 	RedisWriteTimeout time.Duration
+
+	RedisCallTimeout time.Duration
+	RedisBreakerFailureThreshold int
+	RedisBreakerOpenTimeout time.Duration
+	ExpectedReplicas int
 }
 
 func getEnv(key, defaultVal string) string {
@@ -69,6 +150,75 @@ func getEnvAsDurationSeconds(key string, defaultSeconds int) time.Duration {
 	return time.Duration(secs) * time.Second
 }
 
+func getEnvAsDurationMillis(key string, defaultMillis int) time.Duration {
+	ms := getEnvAsInt(key, defaultMillis)
+	if ms <= 0 {
+		return time.Duration(defaultMillis) * time.Millisecond
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// parseLimitWindows parses a comma separated list of limit/windowSeconds
+// pairs, e.g. "1000/3600,20000/86400".
+func parseLimitWindows(raw string) ([]LimitWindow, error) {
+	var windows []LimitWindow
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		limitStr, windowStr, ok := strings.Cut(part, "/")
+		if !ok {
+			return nil, fmt.Errorf("%q: expected limit/windowSeconds", part)
+		}
+
+		limit, err := strconv.Atoi(strings.TrimSpace(limitStr))
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("%q: limit must be a positive integer", part)
+		}
+
+		secs, err := strconv.Atoi(strings.TrimSpace(windowStr))
+		if err != nil || secs <= 0 {
+			return nil, fmt.Errorf("%q: window must be a positive number of seconds", part)
+		}
+
+		windows = append(windows, LimitWindow{Limit: limit, Window: time.Duration(secs) * time.Second})
+	}
+
+	return windows, nil
+}
+
+// parseAdminTokens parses a comma separated list of name:token pairs, e.g.
+// "alice:s3cret,deploy-bot:t0ken".
+func parseAdminTokens(raw string) (map[string]string, error) {
+	tokens := make(map[string]string)
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name, token, ok := strings.Cut(part, ":")
+		name, token = strings.TrimSpace(name), strings.TrimSpace(token)
+		if !ok || name == "" || token == "" {
+			return nil, fmt.Errorf("expected name:token pairs")
+		}
+		if _, dup := tokens[name]; dup {
+			return nil, fmt.Errorf("%q is listed twice", name)
+		}
+		if seen[token] {
+			return nil, fmt.Errorf("%q reuses another name's token", name)
+		}
+
+		tokens[name] = token
+		seen[token] = true
+	}
+
+	return tokens, nil
+}
+
 func normalizeStrategy(s string) RateLimitStrategy {
 	return RateLimitStrategy(strings.ToLower(strings.TrimSpace(s)))
 }
@@ -77,9 +227,17 @@ func normalizeBackend(s string) RateLimitBackend {
 	return RateLimitBackend(strings.ToLower(strings.TrimSpace(s)))
 }
 
+func normalizeFailureMode(s string) FailureMode {
+	return FailureMode(strings.ToLower(strings.TrimSpace(s)))
+}
+
+func normalizeHeaderStyle(s string) HeaderStyle {
+	return HeaderStyle(strings.ToLower(strings.TrimSpace(s)))
+}
+
 func validateStrategy(s RateLimitStrategy) bool {
 	switch s {
-	case FixedWindow, SlidingWindow, TokenBucket:
+	case FixedWindow, SlidingWindow, TokenBucket, GCRA, LeakyBucket:
 		return true
 	default:
 		return false
@@ -95,6 +253,24 @@ func validateBackend(b RateLimitBackend) bool {
 	}
 }
 
+func validateFailureMode(m FailureMode) bool {
+	switch m {
+	case FailOpen, FailClosed:
+		return true
+	default:
+		return false
+	}
+}
+
+func validateHeaderStyle(h HeaderStyle) bool {
+	switch h {
+	case HeadersLegacy, HeadersIETF, HeadersBoth:
+		return true
+	default:
+		return false
+	}
+}
+
 func LoadConfig() Config {
 	if err := godotenv.Load(); err != nil {
 		log.Println("No .env file found, using syatem env vars")
@@ -104,8 +280,8 @@ func LoadConfig() Config {
 	strategy := normalizeStrategy(rawStrategy)
 	if !validateStrategy(strategy) {
 		log.Fatalf(
-			"Invalid RATE_LIMIT_STRATEGY=%q (expected: %s, %s, %s)",
-			rawStrategy, FixedWindow, SlidingWindow, TokenBucket,
+			"Invalid RATE_LIMIT_STRATEGY=%q (expected: %s, %s, %s, %s, %s)",
+			rawStrategy, FixedWindow, SlidingWindow, TokenBucket, GCRA, LeakyBucket,
 		)
 	}
 
@@ -120,6 +296,34 @@ func LoadConfig() Config {
 		)
 	}
 
+	rawFailureMode := getEnv("RATE_LIMIT_FAILURE_MODE", string(FailOpen))
+	failureMode := normalizeFailureMode(rawFailureMode)
+	if !validateFailureMode(failureMode) {
+		log.Fatalf(
+			"Invalid RATE_LIMIT_FAILURE_MODE=%q (expected: %s, %s)",
+			rawFailureMode,
+			FailOpen,
+			FailClosed,
+		)
+	}
+
+	failureStatus := getEnvAsInt("RATE_LIMIT_FAILURE_STATUS", 503)
+	if failureStatus != 503 && failureStatus != 429 {
+		log.Fatalf("RATE_LIMIT_FAILURE_STATUS must be 503 or 429 (got %d)", failureStatus)
+	}
+
+	rawHeaders := getEnv("RATE_LIMIT_HEADERS", string(HeadersLegacy))
+	headers := normalizeHeaderStyle(rawHeaders)
+	if !validateHeaderStyle(headers) {
+		log.Fatalf(
+			"Invalid RATE_LIMIT_HEADERS=%q (expected: %s, %s, %s)",
+			rawHeaders,
+			HeadersLegacy,
+			HeadersIETF,
+			HeadersBoth,
+		)
+	}
+
 	limit := getEnvAsInt("DEFAULT_LIMIT", 10)
 	windowSeconds := getEnvAsInt("DEFAULT_WINDOW_SECONDS", 60)
 
@@ -130,6 +334,144 @@ func LoadConfig() Config {
 		log.Fatalf("DEFAULT_WINDOW_SECONDS must be > 0 (got %d)", limit)
 	}
 
+	extraLimits, err := parseLimitWindows(getEnv("EXTRA_LIMITS", ""))
+	if err != nil {
+		log.Fatalf("Invalid EXTRA_LIMITS: %v", err)
+	}
+
+	seen := map[time.Duration]bool{time.Duration(windowSeconds) * time.Second: true}
+	for _, w := range extraLimits {
+		if seen[w.Window] {
+			log.Fatalf("Invalid EXTRA_LIMITS: window %s is configured more than once", w.Window)
+		}
+		seen[w.Window] = true
+	}
+
+	clientKey, err := ParseClientKey(getEnv("RATE_LIMIT_KEY", string(KeyAPIKey)))
+	if err != nil {
+		log.Fatalf("Invalid RATE_LIMIT_KEY: %v", err)
+	}
+
+	trustedProxies, err := parsePrefixes(getEnv("TRUSTED_PROXIES", ""))
+	if err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+
+	ipAllowlist, err := loadPrefixes(getEnv("IP_ALLOWLIST", ""), getEnv("IP_ALLOWLIST_FILE", ""))
+	if err != nil {
+		log.Fatalf("Invalid IP_ALLOWLIST: %v", err)
+	}
+
+	ipDenylist, err := loadPrefixes(getEnv("IP_DENYLIST", ""), getEnv("IP_DENYLIST_FILE", ""))
+	if err != nil {
+		log.Fatalf("Invalid IP_DENYLIST: %v", err)
+	}
+
+	policyFile := getEnv("POLICY_FILE", "")
+	policy := &Policy{
+		Default: append([]LimitWindow{{Limit: limit, Window: time.Duration(windowSeconds) * time.Second}}, extraLimits...),
+		Exempt: defaultExempt,
+	}
+	if policyFile != "" {
+		if policy, err = LoadPolicy(policyFile); err != nil {
+			log.Fatalf("Invalid POLICY_FILE: %v", err)
+		}
+	}
+
+	reloadSeconds := getEnvAsInt("POLICY_RELOAD_INTERVAL_SECONDS", 0)
+	if reloadSeconds < 0 {
+		log.Fatalf("POLICY_RELOAD_INTERVAL_SECONDS must be >= 0 (got %d)", reloadSeconds)
+	}
+
+	queueDepth := getEnvAsInt("RATE_LIMIT_QUEUE_DEPTH", 10)
+	if queueDepth < 0 {
+		log.Fatalf("RATE_LIMIT_QUEUE_DEPTH must be >= 0 (got %d)", queueDepth)
+	}
+	queueMaxWaitMs := getEnvAsInt("RATE_LIMIT_QUEUE_MAX_WAIT_MS", 5000)
+	if queueMaxWaitMs <= 0 {
+		log.Fatalf("RATE_LIMIT_QUEUE_MAX_WAIT_MS must be > 0 (got %d)", queueMaxWaitMs)
+	}
+	queueMaxWait := time.Duration(queueMaxWaitMs) * time.Millisecond
+
+	cleanupInterval := getEnvAsDurationSeconds("CLEANUP_INTERVAL_SECONDS", 60)
+
+	maxConcurrent := getEnvAsInt("MAX_CONCURRENT_REQUESTS", 0)
+	if maxConcurrent < 0 {
+		log.Fatalf("MAX_CONCURRENT_REQUESTS must be >= 0 (got %d)", maxConcurrent)
+	}
+	concurrencyLeaseTTL := getEnvAsDurationSeconds("CONCURRENCY_LEASE_SECONDS", 60)
+
+	rawLogFormat := getEnv("LOG_FORMAT", string(LogText))
+	logFormat := LogFormat(strings.ToLower(strings.TrimSpace(rawLogFormat)))
+	if logFormat != LogText && logFormat != LogJSON {
+		log.Fatalf("Invalid LOG_FORMAT=%q (expected: %s, %s)", rawLogFormat, LogText, LogJSON)
+	}
+
+	var logLevel slog.Level
+	if err := logLevel.UnmarshalText([]byte(getEnv("LOG_LEVEL", "info"))); err != nil {
+		log.Fatalf("Invalid LOG_LEVEL: %v (expected: debug, info, warn, error)", err)
+	}
+
+	listenAddr := getEnv("LISTEN_ADDR", ":8080")
+	httpReadTimeout := getEnvAsDurationSeconds("HTTP_READ_TIMEOUT_SECONDS", 15)
+	httpReadHeaderTimeout := getEnvAsDurationSeconds("HTTP_READ_HEADER_TIMEOUT_SECONDS", 5)
+	httpWriteTimeout := getEnvAsDurationSeconds("HTTP_WRITE_TIMEOUT_SECONDS", 30)
+	httpIdleTimeout := getEnvAsDurationSeconds("HTTP_IDLE_TIMEOUT_SECONDS", 60)
+	shutdownTimeout := getEnvAsDurationSeconds("SHUTDOWN_TIMEOUT_SECONDS", 20)
+
+	metricsAddr := getEnv("METRICS_ADDR", "")
+	if metricsAddr != "" && metricsAddr == listenAddr {
+		log.Fatalf("METRICS_ADDR must differ from LISTEN_ADDR (both %q)", listenAddr)
+	}
+
+	adminAddr := getEnv("ADMIN_ADDR", "")
+	adminTokens, err := parseAdminTokens(getEnv("ADMIN_TOKENS", ""))
+	if err != nil {
+		log.Fatalf("Invalid ADMIN_TOKENS: %v", err)
+	}
+	if token := getEnv("ADMIN_TOKEN", ""); token != "" {
+		if _, ok := adminTokens["admin"]; ok {
+			log.Fatal("ADMIN_TOKEN and an \"admin\" entry in ADMIN_TOKENS cannot both be set")
+		}
+		adminTokens["admin"] = token
+	}
+	if adminAddr != "" && len(adminTokens) == 0 {
+		log.Fatal("ADMIN_TOKEN or ADMIN_TOKENS is required when ADMIN_ADDR is set")
+	}
+
+	overrideSyncSeconds := getEnvAsInt("OVERRIDE_SYNC_INTERVAL_SECONDS", 10)
+	if overrideSyncSeconds <= 0 {
+		log.Fatalf("OVERRIDE_SYNC_INTERVAL_SECONDS must be > 0 (got %d)", overrideSyncSeconds)
+	}
+
+	var apiKeyRegistry RateLimitBackend
+	if raw := getEnv("API_KEY_REGISTRY", ""); raw != "" {
+		apiKeyRegistry = normalizeBackend(raw)
+		if !validateBackend(apiKeyRegistry) {
+			log.Fatalf("Invalid API_KEY_REGISTRY=%q (expected: %s, %s)", raw, InMemory, Redis)
+		}
+		if apiKeyRegistry == Redis && backend != Redis {
+			log.Fatal("API_KEY_REGISTRY=redis requires RATE_LIMIT_BACKEND=redis")
+		}
+	}
+	apiKeysFile := getEnv("API_KEYS_FILE", "")
+	if apiKeysFile != "" && apiKeyRegistry == "" {
+		log.Fatal("API_KEYS_FILE is set but API_KEY_REGISTRY is not")
+	}
+
+	apiKeyCacheSeconds := getEnvAsInt("API_KEY_CACHE_SECONDS", 30)
+	if apiKeyCacheSeconds < 0 {
+		log.Fatalf("API_KEY_CACHE_SECONDS must be >= 0 (got %d)", apiKeyCacheSeconds)
+	}
+
+	if strategy == LeakyBucket && httpWriteTimeout <= queueMaxWait {
+		log.Fatalf(
+			"HTTP_WRITE_TIMEOUT_SECONDS (%s) must exceed RATE_LIMIT_QUEUE_MAX_WAIT_MS (%s), or queued requests time out before they are served",
+			httpWriteTimeout,
+			queueMaxWait,
+		)
+	}
+
 	redisAddr := getEnv("REDIS_ADDR", "localhost:6379")
 	redisPassword := getEnv("REDIS_PASSWORD", "")
 	redisDB := getEnvAsInt("REDIS_DB", 0)
@@ -138,12 +480,68 @@ func LoadConfig() Config {
 	redisReadTimeout := getEnvAsDurationSeconds("REDIS_READ_TIMEOUT_SECONDS", 2)
 	redisWriteTimeout:= getEnvAsDurationSeconds("REDIS_WRITE_TIMEOUT_SECONDS", 2)
 
+	redisCallTimeout := getEnvAsDurationMillis("REDIS_CALL_TIMEOUT_MS", 250)
+	breakerThreshold := getEnvAsInt("REDIS_BREAKER_FAILURE_THRESHOLD", 5)
+	breakerOpenTimeout := getEnvAsDurationSeconds("REDIS_BREAKER_OPEN_SECONDS", 10)
+
+	if breakerThreshold <= 0 {
+		log.Fatalf("REDIS_BREAKER_FAILURE_THRESHOLD must be > 0 (got %d)", breakerThreshold)
+	}
+
+	expectedReplicas := getEnvAsInt("EXPECTED_REPLICAS", 1)
+	if expectedReplicas <= 0 {
+		log.Fatalf("EXPECTED_REPLICAS must be > 0 (got %d)", expectedReplicas)
+	}
+
 	return Config{
 		RateLimitStrategy: strategy,
 		RateLimitBackend: backend,
 
+		RateLimitFailureMode: failureMode,
+		RateLimitFailureStatus: failureStatus,
+
+		RateLimitHeaders: headers,
+
 		DefaultLimit: limit,
 		DefaultWindow: time.Duration(windowSeconds) * time.Second,
+		ExtraLimits: extraLimits,
+
+		ClientKey: clientKey,
+		TrustedProxies: trustedProxies,
+		IPAllowlist: ipAllowlist,
+		IPDenylist: ipDenylist,
+
+		PolicyFile: policyFile,
+		Policy: policy,
+		PolicyReloadInterval: time.Duration(reloadSeconds) * time.Second,
+
+		QueueDepth: queueDepth,
+		QueueMaxWait: queueMaxWait,
+
+		CleanupInterval: cleanupInterval,
+
+		MaxConcurrentRequests: maxConcurrent,
+		ConcurrencyLeaseTTL: concurrencyLeaseTTL,
+
+		LogFormat: logFormat,
+		LogLevel: logLevel,
+
+		ListenAddr: listenAddr,
+		HTTPReadTimeout: httpReadTimeout,
+		HTTPReadHeaderTimeout: httpReadHeaderTimeout,
+		HTTPWriteTimeout: httpWriteTimeout,
+		HTTPIdleTimeout: httpIdleTimeout,
+		ShutdownTimeout: shutdownTimeout,
+
+		MetricsAddr: metricsAddr,
+
+		AdminAddr: adminAddr,
+		AdminTokens: adminTokens,
+		OverrideSyncInterval: time.Duration(overrideSyncSeconds) * time.Second,
+
+		APIKeyRegistry: apiKeyRegistry,
+		APIKeysFile: apiKeysFile,
+		APIKeyCacheTTL: time.Duration(apiKeyCacheSeconds) * time.Second,
 
 		RedisAddr: redisAddr,
 		RedisPassword: redisPassword,
@@ -151,5 +549,10 @@ func LoadConfig() Config {
 		RedisDialTimeout: redisDialTimeout,
 		RedisReadTimeout: redisReadTimeout,
 		RedisWriteTimeout: redisWriteTimeout,
+
+		RedisCallTimeout: redisCallTimeout,
+		RedisBreakerFailureThreshold: breakerThreshold,
+		RedisBreakerOpenTimeout: breakerOpenTimeout,
+		ExpectedReplicas: expectedReplicas,
 	}
 }
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+type KeySource string
+
+const (
+	KeyAPIKey KeySource = "api_key"
+	KeyHeader KeySource = "header"
+	KeyQuery  KeySource = "query"
+	KeyBearer KeySource = "bearer"
+	KeyIP     KeySource = "ip"
+	KeyRoute  KeySource = "route"
+)
+
+// Name is the header or query parameter for the header and query sources.
+type KeyPart struct {
+	Source KeySource
+	Name   string
+}
+
+// RATE_LIMIT_KEY lists comma separated alternatives, tried in order, each made
+// of "+" separated parts, e.g. "api_key+route,ip".
+func ParseClientKey(raw string) ([][]KeyPart, error) {
+	var alternatives [][]KeyPart
+	for _, alt := range strings.Split(raw, ",") {
+		alt = strings.TrimSpace(alt)
+		if alt == "" {
+			continue
+		}
+
+		var parts []KeyPart
+		for _, part := range strings.Split(alt, "+") {
+			source, name, _ := strings.Cut(strings.TrimSpace(part), ":")
+			kp := KeyPart{Source: KeySource(strings.ToLower(source)), Name: strings.TrimSpace(name)}
+
+			switch kp.Source {
+			case KeyHeader, KeyQuery:
+				if kp.Name == "" {
+					return nil, fmt.Errorf("%q: %s needs a name, e.g. %s:X-Client-ID", part, kp.Source, kp.Source)
+				}
+				if strings.ContainsAny(kp.Name, "=|") {
+					return nil, fmt.Errorf("%q: %s name cannot contain = or |", part, kp.Source)
+				}
+			case KeyAPIKey, KeyBearer, KeyIP, KeyRoute:
+				if kp.Name != "" {
+					return nil, fmt.Errorf("%q: %s takes no name", part, kp.Source)
+				}
+			default:
+				return nil, fmt.Errorf("%q: unknown key source (expected: %s, %s, %s, %s, %s, %s)",
+					part, KeyAPIKey, KeyHeader, KeyQuery, KeyBearer, KeyIP, KeyRoute)
+			}
+
+			parts = append(parts, kp)
+		}
+
+		alternatives = append(alternatives, parts)
+	}
+
+	if len(alternatives) == 0 {
+		return nil, fmt.Errorf("no key source given")
+	}
+
+	return alternatives, nil
+}
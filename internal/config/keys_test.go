@@ -0,0 +1,43 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseClientKey(t *testing.T) {
+	got, err := ParseClientKey("api_key+route, header:X-Client-ID, IP")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := [][]KeyPart{
+		{{Source: KeyAPIKey}, {Source: KeyRoute}},
+		{{Source: KeyHeader, Name: "X-Client-ID"}},
+		{{Source: KeyIP}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+
+	for raw, wantErr := range map[string]string{
+		"":            "no key source",
+		"cookie":      "unknown key source",
+		"header":      "needs a name",
+		"ip:x":        "takes no name",
+		"api_key,ip:": "",
+	} {
+		_, err := ParseClientKey(raw)
+		if wantErr == "" {
+			if err != nil {
+				t.Fatalf("%q: unexpected error: %v", raw, err)
+			}
+			continue
+		}
+
+		if err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Fatalf("%q: expected error containing %q, got %v", raw, wantErr, err)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Policy struct {
+	Default []LimitWindow
+	// 0 leaves it to MAX_CONCURRENT_REQUESTS.
+	Concurrency int
+	Tiers       map[string][]LimitWindow
+	Keys        map[string]KeyPolicy
+	Routes      []RouteRule
+	Exempt      []string
+}
+
+type KeyPolicy struct {
+	Tier        string
+	Limits      []LimitWindow
+	Concurrency int
+}
+
+type RouteRule struct {
+	Pattern string
+	Cost    int
+	Limits  []LimitWindow
+}
+
+func (p *Policy) KeyLimits() map[string][]LimitWindow {
+	limits := make(map[string][]LimitWindow, len(p.Keys))
+	for key, kp := range p.Keys {
+		limits[key], _ = p.Resolve(kp)
+	}
+
+	return limits
+}
+
+func (p *Policy) Resolve(kp KeyPolicy) ([]LimitWindow, bool) {
+	if kp.Tier == "" {
+		return kp.Limits, true
+	}
+
+	limits, ok := p.Tiers[kp.Tier]
+	return limits, ok
+}
+
+var defaultExempt = []string{"/health"}
+
+func (p *Policy) RouteCosts() map[string]int {
+	costs := make(map[string]int, len(p.Routes))
+	for _, r := range p.Routes {
+		costs[r.Pattern] = r.Cost
+	}
+
+	return costs
+}
+
+type policyFile struct {
+	Default *defaultJSON            `json:"default"`
+	Tiers   map[string]limitSetJSON `json:"tiers"`
+	Keys    map[string]keyJSON      `json:"keys"`
+	Routes  []routeJSON             `json:"routes"`
+	Exempt  *[]string               `json:"exempt"`
+}
+
+type limitSetJSON struct {
+	Limits []LimitJSON `json:"limits"`
+}
+
+type defaultJSON struct {
+	Limits      []LimitJSON `json:"limits"`
+	Concurrency int         `json:"concurrency"`
+}
+
+type LimitJSON struct {
+	Limit  int    `json:"limit"`
+	Window string `json:"window"`
+}
+
+type keyJSON struct {
+	Tier        string      `json:"tier"`
+	Limits      []LimitJSON `json:"limits"`
+	Concurrency int         `json:"concurrency"`
+}
+
+type routeJSON struct {
+	Pattern string      `json:"pattern"`
+	Cost    *int        `json:"cost"`
+	Limits  []LimitJSON `json:"limits"`
+}
+
+func LoadPolicy(path string) (*Policy, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("policy file: %w", err)
+	}
+	defer f.Close()
+
+	p, err := ParsePolicy(f)
+	if err != nil {
+		return nil, fmt.Errorf("policy file %s: %w", path, err)
+	}
+
+	return p, nil
+}
+
+func ParsePolicy(r io.Reader) (*Policy, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	var raw policyFile
+	if err := dec.Decode(&raw); err != nil {
+		return nil, jsonError(data, err)
+	}
+
+	if raw.Default == nil {
+		return nil, errors.New("default: missing; every policy needs default limits")
+	}
+
+	p := &Policy{
+		Tiers: make(map[string][]LimitWindow, len(raw.Tiers)),
+		Keys:  make(map[string]KeyPolicy, len(raw.Keys)),
+	}
+
+	if p.Default, err = ParseLimits("default", raw.Default.Limits); err != nil {
+		return nil, err
+	}
+	if p.Concurrency, err = ParseConcurrency("default", raw.Default.Concurrency); err != nil {
+		return nil, err
+	}
+
+	for _, name := range sortedKeys(raw.Tiers) {
+		where := fmt.Sprintf("tiers[%q]", name)
+		if strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("%s: tier name must not be empty", where)
+		}
+
+		if p.Tiers[name], err = ParseLimits(where, raw.Tiers[name].Limits); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, key := range sortedKeys(raw.Keys) {
+		where := fmt.Sprintf("keys[%q]", key)
+		if strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("%s: key must not be empty", where)
+		}
+
+		k := raw.Keys[key]
+		concurrency, err := ParseConcurrency(where, k.Concurrency)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case k.Tier != "" && k.Limits != nil:
+			return nil, fmt.Errorf("%s: set either tier or limits, not both", where)
+		case k.Tier != "":
+			if _, ok := p.Tiers[k.Tier]; !ok {
+				return nil, fmt.Errorf("%s: unknown tier %q", where, k.Tier)
+			}
+			p.Keys[key] = KeyPolicy{Tier: k.Tier, Concurrency: concurrency}
+		default:
+			limits, err := ParseLimits(where, k.Limits)
+			if err != nil {
+				return nil, err
+			}
+			p.Keys[key] = KeyPolicy{Limits: limits, Concurrency: concurrency}
+		}
+	}
+
+	seen := make(map[string]bool, len(raw.Routes))
+	mux := http.NewServeMux()
+	for i, rt := range raw.Routes {
+		where := fmt.Sprintf("routes[%d]", i)
+		if seen[rt.Pattern] {
+			return nil, fmt.Errorf("%s: pattern %q is listed more than once", where, rt.Pattern)
+		}
+
+		if err := registerPattern(mux, rt.Pattern); err != nil {
+			return nil, fmt.Errorf("%s: %w", where, err)
+		}
+		seen[rt.Pattern] = true
+
+		rule := RouteRule{Pattern: rt.Pattern, Cost: 1}
+		if rt.Cost == nil && rt.Limits == nil {
+			return nil, fmt.Errorf("%s (%s): set cost, limits or both", where, rt.Pattern)
+		}
+
+		if rt.Cost != nil {
+			if *rt.Cost <= 0 {
+				return nil, fmt.Errorf("%s (%s): cost must be > 0 (got %d)", where, rt.Pattern, *rt.Cost)
+			}
+			rule.Cost = *rt.Cost
+		}
+
+		if rt.Limits != nil {
+			if rule.Limits, err = ParseLimits(fmt.Sprintf("%s (%s)", where, rt.Pattern), rt.Limits); err != nil {
+				return nil, err
+			}
+		}
+
+		p.Routes = append(p.Routes, rule)
+	}
+
+	p.Exempt = defaultExempt
+	if raw.Exempt != nil {
+		p.Exempt = *raw.Exempt
+	}
+
+	exemptMux := http.NewServeMux()
+	for i, pattern := range p.Exempt {
+		if err := registerPattern(exemptMux, pattern); err != nil {
+			return nil, fmt.Errorf("exempt[%d]: %w", i, err)
+		}
+	}
+
+	return p, nil
+}
+
+func ParseLimits(where string, raw []LimitJSON) ([]LimitWindow, error) {
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("%s.limits: at least one limit is required", where)
+	}
+
+	limits := make([]LimitWindow, 0, len(raw))
+	windows := make(map[time.Duration]bool, len(raw))
+
+	for i, l := range raw {
+		at := fmt.Sprintf("%s.limits[%d]", where, i)
+
+		if l.Limit <= 0 {
+			return nil, fmt.Errorf("%s: limit must be > 0 (got %d)", at, l.Limit)
+		}
+
+		window, err := time.ParseDuration(l.Window)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid window %q (use e.g. \"1s\", \"1m\", \"24h\")", at, l.Window)
+		}
+		if window <= 0 {
+			return nil, fmt.Errorf("%s: window must be > 0 (got %q)", at, l.Window)
+		}
+
+		if windows[window] {
+			return nil, fmt.Errorf("%s: window %s is listed more than once", at, window)
+		}
+		windows[window] = true
+
+		limits = append(limits, LimitWindow{Limit: l.Limit, Window: window})
+	}
+
+	return limits, nil
+}
+
+func ParseConcurrency(where string, n int) (int, error) {
+	if n < 0 {
+		return 0, fmt.Errorf("%s.concurrency: must be >= 0 (got %d)", where, n)
+	}
+
+	return n, nil
+}
+
+func registerPattern(mux *http.ServeMux, p string) (err error) {
+	if strings.TrimSpace(p) == "" {
+		return errors.New("pattern must not be empty")
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("invalid pattern %q: %v", p, r)
+		}
+	}()
+
+	mux.Handle(p, http.NotFoundHandler())
+	return nil
+}
+
+func jsonError(data []byte, err error) error {
+	var offset int64
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	default:
+		return err
+	}
+
+	line, col := 1, 1
+	for _, b := range data[:min(offset, int64(len(data)))] {
+		if b == '\n' {
+			line++
+			col = 1
+			continue
+		}
+		col++
+	}
+
+	return fmt.Errorf("line %d, column %d: %w", line, col, err)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
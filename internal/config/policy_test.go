@@ -0,0 +1,143 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePolicy_ResolvesTiersAndOverrides(t *testing.T) {
+	p, err := ParsePolicy(strings.NewReader(`{
+		"default": {"limits": [{"limit": 10, "window": "1m"}], "concurrency": 2},
+		"tiers": {"pro": {"limits": [{"limit": 100, "window": "1m"}, {"limit": 5000, "window": "24h"}]}},
+		"keys": {
+			"acme": {"tier": "pro", "concurrency": 8},
+			"vip": {"limits": [{"limit": 3, "window": "1m"}]}
+		},
+		"routes": [
+			{"pattern": "POST /export", "cost": 4},
+			{"pattern": "POST /orders", "limits": [{"limit": 5, "window": "1m"}]}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(p.Default) != 1 || p.Default[0] != (LimitWindow{Limit: 10, Window: time.Minute}) {
+		t.Fatalf("unexpected default: %+v", p.Default)
+	}
+
+	if p.Concurrency != 2 || p.Keys["acme"].Concurrency != 8 || p.Keys["vip"].Concurrency != 0 {
+		t.Fatalf("unexpected concurrency caps: default %d, keys %+v", p.Concurrency, p.Keys)
+	}
+
+	keys := p.KeyLimits()
+	if len(keys["acme"]) != 2 || keys["acme"][1].Window != 24*time.Hour {
+		t.Fatalf("expected acme to get the pro tier, got %+v", keys["acme"])
+	}
+
+	if len(keys["vip"]) != 1 || keys["vip"][0].Limit != 3 {
+		t.Fatalf("expected vip to keep its own limits, got %+v", keys["vip"])
+	}
+
+	if p.RouteCosts()["POST /export"] != 4 {
+		t.Fatalf("expected route cost 4, got %+v", p.RouteCosts())
+	}
+
+	orders := p.Routes[1]
+	if orders.Cost != 1 || len(orders.Limits) != 1 || orders.Limits[0].Limit != 5 {
+		t.Fatalf("expected orders route with cost 1 and its own limit, got %+v", orders)
+	}
+
+	if len(p.Exempt) != 1 || p.Exempt[0] != "/health" {
+		t.Fatalf("expected /health to be exempt by default, got %v", p.Exempt)
+	}
+}
+
+func TestParsePolicy_ErrorsNameTheOffendingEntry(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		want   string
+	}{
+		{
+			name:   "missing default",
+			policy: `{"keys": {}}`,
+			want:   "default: missing",
+		},
+		{
+			name:   "unknown tier",
+			policy: `{"default": {"limits": [{"limit": 1, "window": "1s"}]}, "keys": {"acme": {"tier": "gold"}}}`,
+			want:   `keys["acme"]: unknown tier "gold"`,
+		},
+		{
+			name:   "bad window",
+			policy: `{"default": {"limits": [{"limit": 1, "window": "1s"}]}, "tiers": {"pro": {"limits": [{"limit": 5, "window": "soon"}]}}}`,
+			want:   `tiers["pro"].limits[0]: invalid window "soon"`,
+		},
+		{
+			name:   "zero limit",
+			policy: `{"default": {"limits": [{"limit": 0, "window": "1s"}]}}`,
+			want:   "default.limits[0]: limit must be > 0",
+		},
+		{
+			name:   "tier and limits",
+			policy: `{"default": {"limits": [{"limit": 1, "window": "1s"}]}, "tiers": {"pro": {"limits": [{"limit": 1, "window": "1s"}]}}, "keys": {"acme": {"tier": "pro", "limits": []}}}`,
+			want:   `keys["acme"]: set either tier or limits, not both`,
+		},
+		{
+			name:   "negative concurrency",
+			policy: `{"default": {"limits": [{"limit": 1, "window": "1s"}]}, "keys": {"acme": {"limits": [{"limit": 1, "window": "1s"}], "concurrency": -1}}}`,
+			want:   `keys["acme"].concurrency: must be >= 0`,
+		},
+		{
+			name:   "bad route pattern",
+			policy: `{"default": {"limits": [{"limit": 1, "window": "1s"}]}, "routes": [{"pattern": "FETCH", "cost": 2}]}`,
+			want:   "routes[0]: invalid pattern",
+		},
+		{
+			name:   "conflicting route patterns",
+			policy: `{"default": {"limits": [{"limit": 1, "window": "1s"}]}, "routes": [{"pattern": "GET /a/{x}", "cost": 2}, {"pattern": "GET /{y}/b", "cost": 3}]}`,
+			want:   "routes[1]: invalid pattern",
+		},
+		{
+			name:   "route without cost or limits",
+			policy: `{"default": {"limits": [{"limit": 1, "window": "1s"}]}, "routes": [{"pattern": "GET /orders"}]}`,
+			want:   "routes[0] (GET /orders): set cost, limits or both",
+		},
+		{
+			name:   "bad exempt pattern",
+			policy: `{"default": {"limits": [{"limit": 1, "window": "1s"}]}, "exempt": ["/health", "BAD PATTERN HERE"]}`,
+			want:   "exempt[1]: invalid pattern",
+		},
+		{
+			name:   "unknown field",
+			policy: `{"default": {"limits": [{"limit": 1, "window": "1s"}]}, "overrides": {}}`,
+			want:   `unknown field "overrides"`,
+		},
+		{
+			name:   "syntax error",
+			policy: "{\n  \"default\": {\n    \"limits\": [,]\n  }\n}",
+			want:   "line 3, column",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParsePolicy(strings.NewReader(tt.policy))
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %q", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestLoadPolicy_ExampleFileIsValid(t *testing.T) {
+	if _, err := LoadPolicy("../../policy.example.json"); err != nil {
+		t.Fatalf("expected the example policy to load, got %v", err)
+	}
+}
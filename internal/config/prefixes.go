@@ -0,0 +1,65 @@
+package config
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+)
+
+// Text after "#" on a line is a comment.
+func parsePrefixes(raw string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, part := range strings.FieldsFunc(stripComments(raw), func(r rune) bool { return r == ',' || r == '\n' }) {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		if strings.Contains(part, "/") {
+			p, err := netip.ParsePrefix(part)
+			if err != nil {
+				return nil, fmt.Errorf("%q: invalid CIDR prefix", part)
+			}
+			prefixes = append(prefixes, p.Masked())
+			continue
+		}
+
+		ip, err := netip.ParseAddr(part)
+		if err != nil {
+			return nil, fmt.Errorf("%q: invalid IP address", part)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(ip, ip.BitLen()))
+	}
+
+	return prefixes, nil
+}
+
+func loadPrefixes(raw, path string) ([]netip.Prefix, error) {
+	prefixes, err := parsePrefixes(raw)
+	if err != nil || path == "" {
+		return prefixes, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fromFile, err := parsePrefixes(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return append(prefixes, fromFile...), nil
+}
+
+func stripComments(raw string) string {
+	lines := strings.Split(raw, "\n")
+	for i, line := range lines {
+		line, _, _ = strings.Cut(line, "#")
+		lines[i] = line
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,18 @@
+package config
+
+import "testing"
+
+func TestParsePrefixes(t *testing.T) {
+	got, err := parsePrefixes("10.0.0.1/8, 2001:db8::1 # office\n\n# partners\n198.51.100.0/24\n")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(got) != 3 || got[0].String() != "10.0.0.0/8" || got[1].String() != "2001:db8::1/128" || got[2].String() != "198.51.100.0/24" {
+		t.Fatalf("unexpected prefixes: %v", got)
+	}
+
+	if _, err := parsePrefixes("10.0.0.0/33"); err == nil {
+		t.Fatalf("expected an invalid prefix to be rejected")
+	}
+}
@@ -0,0 +1,164 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// An invalid policy file is rejected and the current policy stays in place.
+type PolicyReloader struct {
+	path  string
+	apply func(*Policy)
+
+	mu      sync.Mutex
+	current *Policy
+	modTime time.Time
+	size    int64
+}
+
+func NewPolicyReloader(path string, current *Policy, apply func(*Policy)) *PolicyReloader {
+	r := &PolicyReloader{path: path, apply: apply, current: current}
+	if fi, err := os.Stat(path); err == nil {
+		r.modTime, r.size = fi.ModTime(), fi.Size()
+	}
+
+	return r
+}
+
+func (r *PolicyReloader) Reload() (changes []string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if fi, err := os.Stat(r.path); err == nil {
+		r.modTime, r.size = fi.ModTime(), fi.Size()
+	}
+
+	next, err := LoadPolicy(r.path)
+	if err != nil {
+		return nil, err
+	}
+
+	changes = DiffPolicies(r.current, next)
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	r.apply(next)
+	r.current = next
+
+	return changes, nil
+}
+
+func (r *PolicyReloader) Watch(ctx context.Context, interval time.Duration, report func(changes []string, err error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		fi, err := os.Stat(r.path)
+		if err != nil {
+			report(nil, fmt.Errorf("policy file: %w", err))
+			continue
+		}
+
+		r.mu.Lock()
+		changed := !fi.ModTime().Equal(r.modTime) || fi.Size() != r.size
+		r.mu.Unlock()
+
+		if changed {
+			report(r.Reload())
+		}
+	}
+}
+
+func DiffPolicies(prev, next *Policy) []string {
+	var changes []string
+
+	if a, b := formatLimits(prev.Default), formatLimits(next.Default); a != b {
+		changes = append(changes, fmt.Sprintf("default: %s -> %s", a, b))
+	}
+	if prev.Concurrency != next.Concurrency {
+		changes = append(changes, fmt.Sprintf("default.concurrency: %d -> %d", prev.Concurrency, next.Concurrency))
+	}
+
+	changes = append(changes, diffMaps("tiers", prev.Tiers, next.Tiers, formatLimits)...)
+	changes = append(changes, diffMaps("keys", prev.Keys, next.Keys, formatKeyPolicy)...)
+
+	prevRoutes := make(map[string]RouteRule, len(prev.Routes))
+	for _, rt := range prev.Routes {
+		prevRoutes[rt.Pattern] = rt
+	}
+	nextRoutes := make(map[string]RouteRule, len(next.Routes))
+	for _, rt := range next.Routes {
+		nextRoutes[rt.Pattern] = rt
+	}
+	changes = append(changes, diffMaps("routes", prevRoutes, nextRoutes, formatRoute)...)
+
+	if a, b := strings.Join(prev.Exempt, ","), strings.Join(next.Exempt, ","); a != b {
+		changes = append(changes, fmt.Sprintf("exempt: [%s] -> [%s]", a, b))
+	}
+
+	return changes
+}
+
+func diffMaps[V any](section string, prev, next map[string]V, format func(V) string) []string {
+	var changes []string
+
+	for _, k := range sortedKeys(prev) {
+		if _, ok := next[k]; !ok {
+			changes = append(changes, fmt.Sprintf("%s[%q]: removed (was %s)", section, k, format(prev[k])))
+		}
+	}
+
+	for _, k := range sortedKeys(next) {
+		b := format(next[k])
+		old, ok := prev[k]
+		switch {
+		case !ok:
+			changes = append(changes, fmt.Sprintf("%s[%q]: added %s", section, k, b))
+		case format(old) != b:
+			changes = append(changes, fmt.Sprintf("%s[%q]: %s -> %s", section, k, format(old), b))
+		}
+	}
+
+	return changes
+}
+
+func formatLimits(limits []LimitWindow) string {
+	parts := make([]string, 0, len(limits))
+	for _, l := range limits {
+		parts = append(parts, fmt.Sprintf("%d/%s", l.Limit, l.Window))
+	}
+
+	return strings.Join(parts, ",")
+}
+
+func formatKeyPolicy(k KeyPolicy) string {
+	s := formatLimits(k.Limits)
+	if k.Tier != "" {
+		s = "tier " + k.Tier
+	}
+
+	if k.Concurrency > 0 {
+		s += fmt.Sprintf(" concurrency %d", k.Concurrency)
+	}
+
+	return s
+}
+
+func formatRoute(r RouteRule) string {
+	if len(r.Limits) == 0 {
+		return fmt.Sprintf("cost %d", r.Cost)
+	}
+
+	return fmt.Sprintf("cost %d limits %s", r.Cost, formatLimits(r.Limits))
+}
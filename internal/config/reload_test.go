@@ -0,0 +1,76 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writePolicy(t *testing.T, path, policy string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(policy), 0o600); err != nil {
+		t.Fatalf("write policy: %v", err)
+	}
+}
+
+func TestPolicyReloader_AppliesValidChangesAndReportsDiff(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	writePolicy(t, path, `{"default": {"limits": [{"limit": 10, "window": "1m"}]}, "keys": {"vip": {"limits": [{"limit": 3, "window": "1m"}]}}}`)
+
+	current, err := LoadPolicy(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var applied *Policy
+	r := NewPolicyReloader(path, current, func(p *Policy) { applied = p })
+
+	writePolicy(t, path, `{"default": {"limits": [{"limit": 20, "window": "1m"}]}, "keys": {"acme": {"limits": [{"limit": 5, "window": "1s"}]}}}`)
+
+	changes, err := r.Reload()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{
+		"default: 10/1m0s -> 20/1m0s",
+		`keys["vip"]: removed (was 3/1m0s)`,
+		`keys["acme"]: added 5/1s`,
+	}
+	if strings.Join(changes, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected diff:\n%s", strings.Join(changes, "\n"))
+	}
+
+	if applied == nil || applied.Default[0].Limit != 20 {
+		t.Fatalf("expected the new policy to be applied, got %+v", applied)
+	}
+}
+
+func TestPolicyReloader_RejectsInvalidPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	writePolicy(t, path, `{"default": {"limits": [{"limit": 10, "window": "1m"}]}}`)
+
+	current, _ := LoadPolicy(path)
+
+	applied := false
+	r := NewPolicyReloader(path, current, func(*Policy) { applied = true })
+
+	writePolicy(t, path, `{"default": {"limits": [{"limit": -1, "window": "1m"}]}}`)
+
+	if _, err := r.Reload(); err == nil || !strings.Contains(err.Error(), "default.limits[0]") {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+
+	if applied {
+		t.Fatalf("expected invalid policy not to be applied")
+	}
+
+	writePolicy(t, path, `{"default": {"limits": [{"limit": 10, "window": "1m"}]}, "keys": {"vip": {"limits": [{"limit": 3, "window": "1m"}]}}}`)
+
+	changes, err := r.Reload()
+	if err != nil || len(changes) != 1 {
+		t.Fatalf("expected diff against the last valid policy, got %v (err=%v)", changes, err)
+	}
+}
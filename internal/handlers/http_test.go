@@ -1,12 +1,21 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/bellettati/go-rate-limited-api/internal/limiter"
+	"github.com/bellettati/go-rate-limited-api/internal/metrics"
 	"github.com/bellettati/go-rate-limited-api/internal/middleware"
 	"github.com/bellettati/go-rate-limited-api/internal/store"
 )
@@ -100,3 +109,579 @@ func TestProtectedRateLimitExceeded(t *testing.T) {
 		t.Fatalf("expected status 429, got %d", rec.Code)
 	}
 }
+
+func TestRouteCostsChargeExpensiveRoutes(t *testing.T) {
+	clock := limiter.NewFakeClock(time.Now())
+	st := store.NewMemoryStoreWithCleanupInterval(time.Minute)
+	rl := limiter.NewFixedWindowLimiter(st, clock, limiter.LimitConfig{Limit: 5, Window: time.Minute}, nil)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/protected", Protected)
+	mux.HandleFunc("/export", Protected)
+
+	handler := middleware.RateLimit(
+		rl,
+		middleware.WithCost(middleware.RouteCosts(map[string]int{"POST /export": 4})),
+	)(mux)
+
+	req := httptest.NewRequest(http.MethodPost, "/export", nil)
+	req.Header.Set("X-API-Key", "test-key")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	if got := rec.Header().Get("X-RateLimit-Remaining"); got != "1" {
+		t.Fatalf("expected export to cost 4 units, remaining=%s", got)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/protected", nil)
+	req.Header.Set("X-API-Key", "test-key")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("X-RateLimit-Remaining"); got != "0" {
+		t.Fatalf("expected unmatched route to cost 1 unit, remaining=%s", got)
+	}
+}
+
+type unavailableLimiter struct{}
+
+func (unavailableLimiter) AllowN(context.Context, string, int) (limiter.RateLimitResult, error) {
+	return limiter.RateLimitResult{Limit: 10}, errors.New("store unavailable")
+}
+
+func TestStoreOutageFailsOpenByDefault(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/protected", Protected)
+	handler := middleware.RateLimit(unavailableLimiter{})(mux)
+
+	req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+	req.Header.Set("X-API-Key", "test-key")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	if got := rec.Header().Get("X-RateLimit-Degraded"); got != "fail_open" {
+		t.Fatalf("expected degraded header fail_open, got %q", got)
+	}
+}
+
+func TestStoreOutageFailsClosed(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/protected", Protected)
+	handler := middleware.RateLimit(
+		unavailableLimiter{},
+		middleware.WithFailureMode(middleware.FailClosed, http.StatusTooManyRequests),
+	)(mux)
+
+	req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+	req.Header.Set("X-API-Key", "test-key")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429, got %d", rec.Code)
+	}
+
+	if got := rec.Header().Get("X-RateLimit-Degraded"); got != "fail_closed" {
+		t.Fatalf("expected degraded header fail_closed, got %q", got)
+	}
+}
+
+func TestQueueingDelaysInsteadOfRejecting(t *testing.T) {
+	st := store.NewMemoryStoreWithCleanupInterval(time.Minute)
+	newHandler := func(depth int) http.Handler {
+		rl := limiter.NewLeakyBucketLimiter(
+			st,
+			limiter.RealClock{},
+			limiter.LimitConfig{Limit: 20, Window: time.Second},
+			nil,
+			limiter.QueueConfig{Depth: depth},
+		)
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("/protected", Protected)
+		return middleware.RateLimit(rl, middleware.WithQueueing())(mux)
+	}
+
+	serve := func(h http.Handler, apiKey string) (int, time.Duration) {
+		req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+		req.Header.Set("X-API-Key", apiKey)
+		rec := httptest.NewRecorder()
+
+		start := time.Now()
+		h.ServeHTTP(rec, req)
+		return rec.Code, time.Since(start)
+	}
+
+	queueing := newHandler(1)
+	serve(queueing, "queued-key")
+
+	code, elapsed := serve(queueing, "queued-key")
+	if code != http.StatusOK {
+		t.Fatalf("expected queued request to succeed, got %d", code)
+	}
+
+	if elapsed < 40*time.Millisecond {
+		t.Fatalf("expected queued request to be held for one interval, took %s", elapsed)
+	}
+
+	rejecting := newHandler(0)
+	serve(rejecting, "unqueued-key")
+
+	if code, _ := serve(rejecting, "unqueued-key"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429 without a queue, got %d", code)
+	}
+}
+
+func TestConcurrencyLimitRejectsParallelRequests(t *testing.T) {
+	clock := limiter.NewFakeClock(time.Now())
+	st := store.NewMemoryStoreWithCleanupInterval(time.Minute)
+	rl := limiter.NewFixedWindowLimiter(st, clock, limiter.LimitConfig{Limit: 100, Window: time.Minute}, nil)
+	cl := limiter.NewConcurrencyLimiter(st, clock, limiter.LimitConfig{Limit: 1}, nil, time.Minute)
+
+	entered := make(chan struct{})
+	unblock := make(chan struct{})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-unblock
+	})
+	mux.HandleFunc("/protected", Protected)
+
+	handler := middleware.RateLimit(rl, middleware.WithConcurrencyLimit(cl))(mux)
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-API-Key", "test-key")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		serve("/slow")
+	}()
+	<-entered
+
+	rec := serve("/protected")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429 while a request is in flight, got %d", rec.Code)
+	}
+
+	if got := rec.Header().Get("X-RateLimit-Remaining"); got != "" {
+		t.Fatalf("expected rejected request not to be charged against the rate limit, got remaining=%s", got)
+	}
+
+	close(unblock)
+	<-done
+
+	if rec := serve("/protected"); rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200 after the slot was released, got %d", rec.Code)
+	}
+}
+
+func TestMultiWindowPolicyReportsRestrictiveWindow(t *testing.T) {
+	clock := limiter.NewFakeClock(time.Now())
+	st := store.NewMemoryStoreWithCleanupInterval(time.Minute)
+	rl := limiter.NewCompositeLimiter(
+		func(namespace string, def limiter.LimitConfig, overrides map[string]limiter.LimitConfig) limiter.Limiter {
+			return limiter.NewGCRALimiter(store.NewPrefixedStore(st, namespace), clock, def, overrides)
+		},
+		clock,
+		[]limiter.LimitConfig{
+			{Limit: 10, Window: time.Second},
+			{Limit: 2, Window: time.Hour},
+		},
+		nil,
+	)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/protected", Protected)
+	handler := middleware.RateLimit(rl)(mux)
+
+	var rec *httptest.ResponseRecorder
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+		req.Header.Set("X-API-Key", "test-key")
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+	}
+
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429 once the hourly window is exhausted, got %d", rec.Code)
+	}
+
+	if got := rec.Header().Get("X-RateLimit-Limit"); got != "2" {
+		t.Fatalf("expected hourly limit in headers, got %s", got)
+	}
+
+	if got := rec.Header().Get("X-RateLimit-Window"); got != "3600" {
+		t.Fatalf("expected hourly window in headers, got %s", got)
+	}
+}
+
+func TestRouteLimitsUseTheirOwnCounters(t *testing.T) {
+	clock := limiter.NewFakeClock(time.Now())
+	st := store.NewMemoryStoreWithCleanupInterval(time.Minute)
+	newFixed := func(st store.Store, limit int) limiter.Limiter {
+		return limiter.NewFixedWindowLimiter(st, clock, limiter.LimitConfig{Limit: limit, Window: time.Minute}, nil)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/orders", Protected)
+	mux.HandleFunc("/status", Protected)
+
+	handler := middleware.RateLimit(
+		newFixed(st, 3),
+		middleware.WithRoutes(middleware.RouteLimiters(map[string]limiter.Limiter{
+			"POST /orders": newFixed(store.NewPrefixedStore(st, "route:POST /orders"), 1),
+		})),
+		middleware.WithExempt(middleware.Patterns("GET /status")),
+	)(mux)
+
+	serveAs := func(apiKey, method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("X-API-Key", apiKey)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		return serveAs("test-key", method, path)
+	}
+
+	// A client whose key spells another key's route counter only spends its
+	// own default budget.
+	for range 3 {
+		serveAs("route:POST /orders:test-key", http.MethodGet, "/orders")
+	}
+
+	if rec := serve(http.MethodPost, "/orders"); rec.Code != http.StatusOK {
+		t.Fatalf("expected first order to be allowed, got %d", rec.Code)
+	}
+
+	if rec := serve(http.MethodPost, "/orders"); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected second order to hit the route limit, got %d", rec.Code)
+	}
+
+	rec := serve(http.MethodGet, "/orders")
+	if rec.Code != http.StatusOK || rec.Header().Get("X-RateLimit-Remaining") != "2" {
+		t.Fatalf("expected GET to use the untouched default counter, got %d remaining=%s", rec.Code, rec.Header().Get("X-RateLimit-Remaining"))
+	}
+
+	for i := 0; i < 5; i++ {
+		if rec := serve(http.MethodGet, "/status"); rec.Code != http.StatusOK || rec.Header().Get("X-RateLimit-Limit") != "" {
+			t.Fatalf("expected exempt route to bypass rate limiting, got %d", rec.Code)
+		}
+	}
+}
+
+func TestAnonymousRequestsAreLimitedByIP(t *testing.T) {
+	clock := limiter.NewFakeClock(time.Now())
+	st := store.NewMemoryStoreWithCleanupInterval(time.Minute)
+	rl := limiter.NewFixedWindowLimiter(st, clock, limiter.LimitConfig{Limit: 1, Window: time.Minute}, nil)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/protected", Protected)
+
+	handler := middleware.RateLimit(
+		rl,
+		middleware.WithKeyFunc(middleware.FirstOf(
+			middleware.Header("X-API-Key"),
+			middleware.ClientIP(netip.MustParsePrefix("10.0.0.0/8")),
+		)),
+	)(mux)
+
+	serve := func(remote, forwardedFor string) int {
+		req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+		req.RemoteAddr = remote
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := serve("10.0.0.2:1234", "198.51.100.1"); code != http.StatusOK {
+		t.Fatalf("expected anonymous request to be allowed, got %d", code)
+	}
+
+	if code := serve("10.0.0.3:1234", "198.51.100.1"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected the same client behind another proxy to share its limit, got %d", code)
+	}
+
+	if code := serve("10.0.0.2:1234", "198.51.100.2"); code != http.StatusOK {
+		t.Fatalf("expected another client to have its own limit, got %d", code)
+	}
+}
+
+func TestIPRulesRunBeforeTheLimiter(t *testing.T) {
+	clock := limiter.NewFakeClock(time.Now())
+	st := store.NewMemoryStoreWithCleanupInterval(time.Minute)
+	rl := limiter.NewFixedWindowLimiter(st, clock, limiter.LimitConfig{Limit: 1, Window: time.Minute}, nil)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/protected", Protected)
+
+	handler := middleware.RateLimit(
+		rl,
+		middleware.WithIPRules(middleware.NewIPRules(
+			[]netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")},
+			[]netip.Prefix{netip.MustParsePrefix("2001:db8::/32")},
+		)),
+	)(mux)
+
+	serve := func(remote string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+		req.RemoteAddr = remote
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := 0; i < 3; i++ {
+		rec := serve("192.0.2.10:1234")
+		if rec.Code != http.StatusOK || rec.Header().Get("X-RateLimit-Limit") != "" {
+			t.Fatalf("expected allowlisted address to bypass rate limiting, got %d", rec.Code)
+		}
+	}
+
+	if rec := serve("[2001:db8::1]:1234"); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected denylisted address to get 403, got %d", rec.Code)
+	}
+
+	if rec := serve("203.0.113.1:1234"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected other addresses to go through the usual checks, got %d", rec.Code)
+	}
+}
+
+func TestIETFHeadersListEveryWindow(t *testing.T) {
+	clock := limiter.NewFakeClock(time.Now())
+	st := store.NewMemoryStoreWithCleanupInterval(time.Minute)
+	rl := limiter.NewCompositeLimiter(
+		func(namespace string, def limiter.LimitConfig, overrides map[string]limiter.LimitConfig) limiter.Limiter {
+			return limiter.NewGCRALimiter(store.NewPrefixedStore(st, namespace), clock, def, overrides)
+		},
+		clock,
+		[]limiter.LimitConfig{
+			{Limit: 10, Window: time.Second},
+			{Limit: 2, Window: time.Hour},
+		},
+		nil,
+	)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/protected", Protected)
+	handler := middleware.RateLimit(rl, middleware.WithHeaders(middleware.HeadersBoth))(mux)
+
+	serve := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+		req.Header.Set("X-API-Key", "test-key")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve()
+	if got := rec.Header().Get("RateLimit-Policy"); got != `"1h";q=2;w=3600, "1s";q=10;w=1` {
+		t.Fatalf("unexpected RateLimit-Policy: %s", got)
+	}
+
+	if got := rec.Header().Get("RateLimit"); !strings.HasPrefix(got, `"1h";r=1;t=`) || !strings.Contains(got, `, "1s";r=9;t=`) {
+		t.Fatalf("unexpected RateLimit: %s", got)
+	}
+
+	if rec.Header().Get("X-RateLimit-Limit") != "2" || rec.Header().Get("Retry-After") != "" {
+		t.Fatalf("expected legacy headers and no Retry-After on an allowed request")
+	}
+
+	serve()
+	rec = serve()
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429, got %d", rec.Code)
+	}
+
+	retry, err := strconv.Atoi(rec.Header().Get("Retry-After"))
+	if err != nil || retry < 1 || retry > 1800 {
+		t.Fatalf("expected Retry-After within the hourly emission interval, got %q", rec.Header().Get("Retry-After"))
+	}
+}
+
+func TestRejectionsNegotiateProblemDetails(t *testing.T) {
+	handler := setupTestServer()
+
+	serve := func(apiKey, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		req.Header.Set("X-Request-ID", "req-123")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve("", "application/problem+json")
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("Content-Type") != "application/problem+json" {
+		t.Fatalf("expected a 401 problem, got %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	serve("test-key", "")
+	serve("test-key", "")
+
+	rec = serve("test-key", "text/html;q=0.9, application/json")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429, got %d", rec.Code)
+	}
+
+	var body struct {
+		Status     int    `json:"status"`
+		Detail     string `json:"detail"`
+		Limit      int    `json:"limit"`
+		Remaining  *int   `json:"remaining"`
+		Reset      int64  `json:"reset"`
+		RetryAfter int64  `json:"retry_after"`
+		Policy     string `json:"policy"`
+		RequestID  string `json:"request_id"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("expected a JSON body, got %q: %v", rec.Body.String(), err)
+	}
+
+	if body.Status != 429 || body.Limit != 2 || body.Remaining == nil || *body.Remaining != 0 ||
+		body.Reset == 0 || body.RetryAfter < 1 || body.Policy != "1m" || body.RequestID != "req-123" {
+		t.Fatalf("unexpected problem: %+v", body)
+	}
+
+	rec = serve("test-key", "*/*")
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") || !strings.Contains(rec.Body.String(), "rate limit exceeded") {
+		t.Fatalf("expected the plain text fallback, got %s %q", ct, rec.Body.String())
+	}
+}
+
+func TestCustomDenyHandler(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/protected", Protected)
+
+	var got middleware.Denial
+	handler := middleware.RateLimit(
+		unavailableLimiter{},
+		middleware.WithFailureMode(middleware.FailClosed, 0),
+		middleware.WithDenyHandler(func(w http.ResponseWriter, r *http.Request, d middleware.Denial) {
+			got = d
+			w.WriteHeader(http.StatusTeapot)
+		}),
+	)(mux)
+
+	req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+	req.Header.Set("X-API-Key", "test-key")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusTeapot || got.Status != http.StatusServiceUnavailable || got.Detail != "rate limiter unavailable" {
+		t.Fatalf("expected the custom handler to answer, got %d %+v", rec.Code, got)
+	}
+}
+
+func TestRequestsAreLoggedWithRequestID(t *testing.T) {
+	clock := limiter.NewFakeClock(time.Now())
+	st := store.NewTimedStore(store.NewMemoryStoreWithCleanupInterval(time.Minute), nil)
+	rl := limiter.NewFixedWindowLimiter(st, clock, limiter.LimitConfig{Limit: 1, Window: time.Minute}, nil)
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil)).With("strategy", "fixed_window")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/protected", Protected)
+	handler := middleware.RateLimit(rl, middleware.WithLogger(logger))(mux)
+
+	serve := func(requestID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+		req.Header.Set("X-API-Key", "test-key")
+		if requestID != "" {
+			req.Header.Set("X-Request-ID", requestID)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve("")
+	generated := rec.Header().Get("X-Request-ID")
+	if len(generated) != 32 {
+		t.Fatalf("expected a generated request ID, got %q", generated)
+	}
+
+	if rec := serve("client-chosen-id"); rec.Header().Get("X-Request-ID") != "client-chosen-id" {
+		t.Fatalf("expected the client's request ID to be propagated, got %q", rec.Header().Get("X-Request-ID"))
+	}
+
+	var lines []map[string]any
+	dec := json.NewDecoder(&logs)
+	for dec.More() {
+		var line map[string]any
+		if err := dec.Decode(&line); err != nil {
+			t.Fatalf("expected JSON log lines: %v", err)
+		}
+		lines = append(lines, line)
+	}
+
+	if len(lines) != 2 {
+		t.Fatalf("expected one line per request, got %d", len(lines))
+	}
+
+	allowed, denied := lines[0], lines[1]
+	if allowed["allowed"] != true || allowed["request_id"] != generated || allowed["strategy"] != "fixed_window" ||
+		allowed["policy"] != "1m" || allowed["cost"] != float64(1) || allowed["store_latency"] == nil {
+		t.Fatalf("unexpected allowed line: %v", allowed)
+	}
+
+	if denied["allowed"] != false || denied["status"] != float64(http.StatusTooManyRequests) || denied["request_id"] != "client-chosen-id" {
+		t.Fatalf("unexpected denied line: %v", denied)
+	}
+}
+
+func TestDecisionsAreCountedInMetrics(t *testing.T) {
+	clock := limiter.NewFakeClock(time.Now())
+	st := store.NewMemoryStoreWithCleanupInterval(time.Minute)
+	rl := limiter.NewFixedWindowLimiter(st, clock, limiter.LimitConfig{Limit: 1, Window: time.Minute}, nil)
+
+	reg := metrics.NewRegistry()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/protected", Protected)
+	handler := middleware.RateLimit(rl, middleware.WithMetrics(middleware.NewMetrics(reg)))(mux)
+
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+		req.Header.Set("X-API-Key", "test-key")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	rec := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+
+	for _, want := range []string{
+		`ratelimit_decisions_total{decision="allowed",policy="1m",route=""} 1`,
+		`ratelimit_decisions_total{decision="denied",policy="1m",route=""} 2`,
+		`ratelimit_decision_duration_seconds_count 3`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected %q in metrics:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package limiter
+
+import (
+	"sync"
+	"time"
+)
+
+const defaultCleanupInterval = time.Minute
+
+// cleanupLoop runs on clock time so tests can drive it with a FakeClock.
+type cleanupLoop struct {
+	stopOnce sync.Once
+	stopCh   chan struct{}
+	done     chan struct{}
+}
+
+func startCleanup(clock Clock, interval time.Duration, fn func()) *cleanupLoop {
+	if interval <= 0 {
+		interval = defaultCleanupInterval
+	}
+
+	c := &cleanupLoop{stopCh: make(chan struct{}), done: make(chan struct{})}
+
+	go func() {
+		defer close(c.done)
+
+		for {
+			select {
+			case <-clock.After(interval):
+				fn()
+			case <-c.stopCh:
+				return
+			}
+		}
+	}()
+
+	return c
+}
+
+func (c *cleanupLoop) stop() {
+	c.stopOnce.Do(func() { close(c.stopCh) })
+	<-c.done
+}
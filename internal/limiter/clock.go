@@ -1,9 +1,13 @@
 package limiter
 
-import "time"
+import (
+	"sync"
+	"time"
+)
 
 type Clock interface {
 	Now() time.Time
+	After(d time.Duration) <-chan time.Time
 }
 
 type RealClock struct {}
@@ -12,8 +16,19 @@ func (RealClock) Now() time.Time {
 	return time.Now()
 }
 
+func (RealClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
 type FakeClock struct {
+	mu      sync.Mutex
 	current time.Time
+	waiters []fakeWaiter
 }
 
 func NewFakeClock(start time.Time) *FakeClock {
@@ -21,9 +36,48 @@ func NewFakeClock(start time.Time) *FakeClock {
 }
 
 func (f *FakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
 	return f.current
 }
 
+func (f *FakeClock) After(d time.Duration) <-chan time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	at := f.current.Add(d)
+	if d <= 0 {
+		ch <- f.current
+		return ch
+	}
+
+	f.waiters = append(f.waiters, fakeWaiter{at: at, ch: ch})
+	return ch
+}
+
+// Waiters lets tests wait for a goroutine to block before advancing the clock.
+func (f *FakeClock) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.waiters)
+}
+
 func (f *FakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
 	f.current = f.current.Add(d)
-}
\ No newline at end of file
+
+	pending := f.waiters[:0]
+	for _, w := range f.waiters {
+		if w.at.After(f.current) {
+			pending = append(pending, w)
+			continue
+		}
+		w.ch <- f.current
+	}
+	f.waiters = pending
+}
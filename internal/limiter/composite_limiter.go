@@ -0,0 +1,341 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Limiters sharing a store must keep their keys under namespace, e.g. with
+// store.NewPrefixedStore.
+type BuildFunc func(namespace string, defaultLimit LimitConfig, overrides map[string]LimitConfig) Limiter
+
+type windowLimiter struct {
+	window     time.Duration
+	limiter    Limiter
+	hasDefault bool
+	overridden map[string]bool
+}
+
+type compositeState struct {
+	windows   []windowLimiter
+	overrides map[string][]LimitConfig
+}
+
+type CompositeLimiter struct {
+	build BuildFunc
+	clock Clock
+
+	mu    sync.Mutex
+	state atomic.Pointer[compositeState]
+}
+
+func NewCompositeLimiter(build BuildFunc, clock Clock, defaultPolicy []LimitConfig, overrides map[string][]LimitConfig) *CompositeLimiter {
+	c := &CompositeLimiter{build: build, clock: clock}
+	c.SetPolicy(defaultPolicy, overrides)
+	return c
+}
+
+// SetPolicy keeps the counters of windows that exist before and after when
+// their limiter is Reconfigurable.
+func (c *CompositeLimiter) SetPolicy(defaultPolicy []LimitConfig, overrides map[string][]LimitConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if overrides == nil {
+		overrides = make(map[string][]LimitConfig)
+	}
+
+	next := &compositeState{overrides: overrides}
+
+	existing := make(map[time.Duration]Limiter)
+	if prev := c.state.Load(); prev != nil {
+		for _, w := range prev.windows {
+			existing[w.window] = w.limiter
+		}
+	}
+
+	defaults := make(map[time.Duration]LimitConfig)
+	perWindow := make(map[time.Duration]map[string]LimitConfig)
+	for _, cfg := range defaultPolicy {
+		defaults[cfg.Window] = cfg
+		perWindow[cfg.Window] = make(map[string]LimitConfig)
+	}
+
+	overridden := make(map[time.Duration]map[string]bool)
+	for apiKey, policy := range overrides {
+		for _, cfg := range policy {
+			if perWindow[cfg.Window] == nil {
+				perWindow[cfg.Window] = make(map[string]LimitConfig)
+			}
+			if overridden[cfg.Window] == nil {
+				overridden[cfg.Window] = make(map[string]bool)
+			}
+			perWindow[cfg.Window][apiKey] = cfg
+			overridden[cfg.Window][apiKey] = true
+		}
+	}
+
+	for window, keyed := range perWindow {
+		def, hasDefault := defaults[window]
+		if !hasDefault {
+			// Only overridden keys use this window, so the default is never read.
+			def = LimitConfig{Limit: 1, Window: window}
+		}
+
+		l, ok := existing[window]
+		if r, reconfigurable := l.(Reconfigurable); ok && reconfigurable {
+			r.SetLimits(def, keyed)
+			delete(existing, window)
+		} else {
+			l = c.build(window.String(), def, keyed)
+		}
+
+		next.windows = append(next.windows, windowLimiter{
+			window:     window,
+			limiter:    l,
+			hasDefault: hasDefault,
+			overridden: overridden[window],
+		})
+	}
+
+	// Longest windows first: when several are exhausted, the one reported is
+	// the one that takes longest to recover.
+	sort.Slice(next.windows, func(i, j int) bool {
+		return next.windows[i].window > next.windows[j].window
+	})
+
+	c.state.Store(next)
+
+	// Requests already holding the old state may still use these; closing
+	// only stops their background work.
+	for _, l := range existing {
+		_ = closeLimiter(l)
+	}
+}
+
+func (c *CompositeLimiter) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var errs []error
+	for _, w := range c.state.Load().windows {
+		errs = append(errs, closeLimiter(w.limiter))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *CompositeLimiter) TrackedKeys() int {
+	total := 0
+	for _, w := range c.state.Load().windows {
+		if t, ok := w.limiter.(interface{ TrackedKeys() int }); ok {
+			total += t.TrackedKeys()
+		}
+	}
+
+	return total
+}
+
+func closeLimiter(l Limiter) error {
+	if c, ok := l.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
+
+func (s *compositeState) applies(w windowLimiter, apiKey string) bool {
+	if _, ok := s.overrides[apiKey]; ok {
+		return w.overridden[apiKey]
+	}
+
+	return w.hasDefault
+}
+
+func (c *CompositeLimiter) Allow(apiKey string) RateLimitResult {
+	res, err := c.AllowN(context.Background(), apiKey, 1)
+	if err != nil {
+		return failOpen(res)
+	}
+
+	return res
+}
+
+func (c *CompositeLimiter) AllowN(ctx context.Context, apiKey string, n int) (RateLimitResult, error) {
+	r, err := c.ReserveN(ctx, apiKey, n)
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+
+	return r.Result(), nil
+}
+
+func (c *CompositeLimiter) Reserve(ctx context.Context, apiKey string) (*Reservation, error) {
+	return c.ReserveN(ctx, apiKey, 1)
+}
+
+// ReserveN reports the most restrictive window.
+func (c *CompositeLimiter) ReserveN(ctx context.Context, apiKey string, n int) (*Reservation, error) {
+	var (
+		held     []*Reservation
+		windows  []RateLimitResult
+		result   RateLimitResult
+		delay    time.Duration
+		fallback bool
+	)
+
+	release := func(ctx context.Context) error {
+		var errs []error
+		for _, r := range held {
+			errs = append(errs, r.Cancel(ctx))
+		}
+		return errors.Join(errs...)
+	}
+
+	state := c.state.Load()
+	for _, w := range state.windows {
+		if !state.applies(w, apiKey) {
+			continue
+		}
+
+		r, err := Reserve(ctx, w.limiter, apiKey, n)
+		if err != nil {
+			_ = release(context.WithoutCancel(ctx))
+			return nil, fmt.Errorf("window %s: %w", w.window, err)
+		}
+
+		res := r.Result()
+		fallback = fallback || res.Fallback
+		windows = append(windows, res)
+
+		if !r.OK() {
+			_ = release(context.WithoutCancel(ctx))
+			res.Fallback = fallback
+			res.Windows = windows
+			return newReservation(res, 0, nil), nil
+		}
+
+		held = append(held, r)
+		delay = max(delay, r.Delay())
+
+		if len(held) == 1 || moreRestrictive(res, result) {
+			result = res
+		}
+	}
+
+	// A key whose policy has no windows is not limited at all.
+	if len(held) == 0 {
+		result.Allowed = true
+	}
+
+	result.Fallback = fallback
+	result.Windows = windows
+	return newReservation(result, delay, release), nil
+}
+
+func (c *CompositeLimiter) Wait(ctx context.Context, apiKey string) error {
+	return c.WaitN(ctx, apiKey, 1)
+}
+
+func (c *CompositeLimiter) WaitN(ctx context.Context, apiKey string, n int) error {
+	return waitN(ctx, c, c.clock, apiKey, n)
+}
+
+func (c *CompositeLimiter) Inspect(ctx context.Context, apiKey string) (KeyState, error) {
+	return c.inspect(ctx, c.state.Load(), apiKey)
+}
+
+func (c *CompositeLimiter) inspect(ctx context.Context, state *compositeState, apiKey string) (KeyState, error) {
+	result := KeyState{Key: apiKey}
+
+	for _, w := range state.windows {
+		if !state.applies(w, apiKey) {
+			continue
+		}
+
+		inspector, ok := w.limiter.(Inspector)
+		if !ok {
+			return KeyState{}, ErrNotInspectable
+		}
+
+		ks, err := inspector.Inspect(ctx, apiKey)
+		if err != nil {
+			return KeyState{}, fmt.Errorf("window %s: %w", w.window, err)
+		}
+		ks.Key = apiKey
+
+		windows := append(result.Windows, ks)
+		if len(windows) == 1 || ks.Remaining < result.Remaining || (ks.Remaining == result.Remaining && ks.ResetAt.After(result.ResetAt)) {
+			result = ks
+		}
+		result.Windows = windows
+	}
+
+	return result, nil
+}
+
+func (c *CompositeLimiter) Reset(ctx context.Context, apiKey string) error {
+	state := c.state.Load()
+
+	var errs []error
+	for _, w := range state.windows {
+		inspector, ok := w.limiter.(Inspector)
+		if !ok {
+			return ErrNotInspectable
+		}
+
+		if err := inspector.Reset(ctx, apiKey); err != nil {
+			errs = append(errs, fmt.Errorf("window %s: %w", w.window, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Any key among the top n overall is among the top n of its most used
+// window, so asking each window for n is enough.
+func (c *CompositeLimiter) TopKeys(ctx context.Context, n int, owns func(apiKey string) bool) ([]KeyState, error) {
+	state := c.state.Load()
+
+	candidates := make(map[string]bool)
+	for _, w := range state.windows {
+		inspector, ok := w.limiter.(Inspector)
+		if !ok {
+			return nil, ErrNotInspectable
+		}
+
+		top, err := inspector.TopKeys(ctx, n, owns)
+		if err != nil {
+			return nil, fmt.Errorf("window %s: %w", w.window, err)
+		}
+
+		for _, ks := range top {
+			candidates[ks.Key] = true
+		}
+	}
+
+	states := make([]KeyState, 0, len(candidates))
+	for apiKey := range candidates {
+		ks, err := c.inspect(ctx, state, apiKey)
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, ks)
+	}
+
+	return TopByUsage(states, n), nil
+}
+
+func moreRestrictive(a, b RateLimitResult) bool {
+	if a.Remaining != b.Remaining {
+		return a.Remaining < b.Remaining
+	}
+
+	return a.ResetAt.After(b.ResetAt)
+}
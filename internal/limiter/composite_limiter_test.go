@@ -0,0 +1,262 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bellettati/go-rate-limited-api/internal/store"
+)
+
+func newTestComposite(clock Clock, defaultPolicy []LimitConfig, overrides map[string][]LimitConfig) *CompositeLimiter {
+	st := store.NewMemoryStoreWithCleanupInterval(time.Minute)
+	build := func(namespace string, def LimitConfig, overrides map[string]LimitConfig) Limiter {
+		return NewFixedWindowLimiter(store.NewPrefixedStore(st, namespace), clock, def, overrides)
+	}
+
+	return NewCompositeLimiter(build, clock, defaultPolicy, overrides)
+}
+
+// nextHour starts fake clocks ahead of real time, so that the store's real
+// time TTLs outlive every window the test walks through.
+func nextHour() time.Time {
+	return windowAligned(time.Hour).Add(time.Hour)
+}
+
+func TestComposite_DeniesWhenAnyWindowIsExhausted(t *testing.T) {
+	clock := NewFakeClock(nextHour())
+	limiter := newTestComposite(clock, []LimitConfig{
+		{Limit: 2, Window: time.Second},
+		{Limit: 3, Window: time.Hour},
+	}, nil)
+
+	for i := 0; i < 2; i++ {
+		if res := limiter.Allow("test-key"); !res.Allowed {
+			t.Fatalf("expected request %d to be allowed", i+1)
+		}
+	}
+
+	res := limiter.Allow("test-key")
+	if res.Allowed {
+		t.Fatalf("expected per-second window to deny the third request")
+	}
+
+	if res.Window != time.Second {
+		t.Fatalf("expected the per-second window to be reported, got %s", res.Window)
+	}
+
+	clock.Advance(time.Second)
+	if res := limiter.Allow("test-key"); !res.Allowed {
+		t.Fatalf("expected request in the next second to be allowed")
+	}
+
+	clock.Advance(time.Second)
+	res = limiter.Allow("test-key")
+	if res.Allowed {
+		t.Fatalf("expected hourly window to deny once 3 requests were made")
+	}
+
+	if res.Window != time.Hour || res.Limit != 3 {
+		t.Fatalf("expected the hourly window to be reported, got limit=%d window=%s", res.Limit, res.Window)
+	}
+}
+
+func TestComposite_KeysSpelledLikeAnotherWindowStaySeparate(t *testing.T) {
+	clock := NewFakeClock(nextHour())
+	limiter := newTestComposite(clock, []LimitConfig{
+		{Limit: 5, Window: time.Second},
+		{Limit: 2, Window: time.Hour},
+	}, nil)
+	ctx := context.Background()
+
+	for range 3 {
+		limiter.Allow("victim@1h0m0s")
+	}
+
+	if res := limiter.Allow("victim"); !res.Allowed || res.Remaining != 1 {
+		t.Fatalf("expected victim's hourly window to be untouched, got %+v", res)
+	}
+
+	top, err := limiter.TopKeys(ctx, 10, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(top) != 2 || top[0].Key != "victim@1h0m0s" || top[1].Key != "victim" {
+		t.Fatalf("expected both keys reported as sent, got %+v", top)
+	}
+}
+
+func TestComposite_DoesNotChargeWindowsThatAllowedWhenOneDenies(t *testing.T) {
+	clock := NewFakeClock(nextHour())
+	limiter := newTestComposite(clock, []LimitConfig{
+		{Limit: 1, Window: time.Second},
+		{Limit: 3, Window: time.Hour},
+	}, nil)
+
+	limiter.Allow("test-key")
+
+	// Denied by the per-second window; the hourly window must not count these.
+	for i := 0; i < 5; i++ {
+		limiter.Allow("test-key")
+	}
+
+	for i := 0; i < 2; i++ {
+		clock.Advance(time.Second)
+		if res := limiter.Allow("test-key"); !res.Allowed {
+			t.Fatalf("expected hourly window to still have room, denied at %d", i+1)
+		}
+	}
+}
+
+func TestComposite_ReportsMostRestrictiveWindowWhenAllowed(t *testing.T) {
+	clock := NewFakeClock(nextHour())
+	limiter := newTestComposite(clock, []LimitConfig{
+		{Limit: 10, Window: time.Second},
+		{Limit: 3, Window: time.Hour},
+	}, nil)
+
+	res := limiter.Allow("test-key")
+	if res.Remaining != 2 || res.Window != time.Hour {
+		t.Fatalf("expected hourly window with 2 remaining, got remaining=%d window=%s", res.Remaining, res.Window)
+	}
+
+	if len(res.Windows) != 2 || res.Windows[0].Window != time.Hour || res.Windows[1].Remaining != 9 {
+		t.Fatalf("expected both windows to be reported, longest first, got %+v", res.Windows)
+	}
+}
+
+func TestComposite_OverridesReplaceTheWholePolicy(t *testing.T) {
+	clock := NewFakeClock(nextHour())
+	limiter := newTestComposite(clock, []LimitConfig{
+		{Limit: 1, Window: time.Second},
+		{Limit: 100, Window: time.Hour},
+	}, map[string][]LimitConfig{
+		"partner": {{Limit: 5, Window: time.Minute}},
+	})
+
+	for i := 0; i < 5; i++ {
+		if res := limiter.Allow("partner"); !res.Allowed {
+			t.Fatalf("expected partner request %d to be allowed", i+1)
+		}
+	}
+
+	res := limiter.Allow("partner")
+	if res.Allowed || res.Window != time.Minute {
+		t.Fatalf("expected partner to be limited by its own window, got %+v", res)
+	}
+
+	if res := limiter.Allow("test-key"); !res.Allowed {
+		t.Fatalf("expected default keys to keep the default policy")
+	}
+}
+
+func TestComposite_CancelRefundsEveryWindow(t *testing.T) {
+	clock := NewFakeClock(nextHour())
+	limiter := newTestComposite(clock, []LimitConfig{
+		{Limit: 1, Window: time.Second},
+		{Limit: 1, Window: time.Hour},
+	}, nil)
+	ctx := context.Background()
+
+	r, err := limiter.Reserve(ctx, "test-key")
+	if err != nil || !r.OK() {
+		t.Fatalf("expected reservation, got ok=%v err=%v", r.OK(), err)
+	}
+
+	if err := r.Cancel(ctx); err != nil {
+		t.Fatalf("unexpected cancel error: %v", err)
+	}
+
+	if res := limiter.Allow("test-key"); !res.Allowed {
+		t.Fatalf("expected both windows to be refunded")
+	}
+}
+
+func TestComposite_WaitUsesTheLimiterClock(t *testing.T) {
+	clock := NewFakeClock(nextHour())
+	limiter := newTestComposite(clock, []LimitConfig{{Limit: 1, Window: time.Second}}, nil)
+
+	limiter.Allow("test-key")
+
+	done := make(chan error, 1)
+	go func() {
+		done <- limiter.Wait(context.Background(), "test-key")
+	}()
+
+	for clock.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(time.Second)
+
+	if err := <-done; err != nil {
+		t.Fatalf("expected Wait to succeed once the fake clock reached the next window, got %v", err)
+	}
+}
+
+func TestComposite_SetPolicyKeepsCounters(t *testing.T) {
+	clock := NewFakeClock(nextHour())
+	limiter := newTestComposite(clock, []LimitConfig{{Limit: 3, Window: time.Hour}}, nil)
+
+	limiter.Allow("test-key")
+	limiter.Allow("test-key")
+
+	limiter.SetPolicy(
+		[]LimitConfig{{Limit: 4, Window: time.Hour}, {Limit: 10, Window: time.Second}},
+		map[string][]LimitConfig{"vip": {{Limit: 1, Window: time.Minute}}},
+	)
+
+	res := limiter.Allow("test-key")
+	if !res.Allowed || res.Remaining != 1 {
+		t.Fatalf("expected hourly counter to survive the reload with 1 left, got %+v", res)
+	}
+
+	limiter.Allow("test-key")
+	if res := limiter.Allow("test-key"); res.Allowed {
+		t.Fatalf("expected the new hourly limit of 4 to apply")
+	}
+
+	limiter.Allow("vip")
+	if res := limiter.Allow("vip"); res.Allowed || res.Window != time.Minute {
+		t.Fatalf("expected the new vip override to apply, got %+v", res)
+	}
+}
+
+type closeRecordingLimiter struct {
+	*TokenBucketLimiter
+	closed bool
+}
+
+func (r *closeRecordingLimiter) Close() error {
+	r.closed = true
+	return r.TokenBucketLimiter.Close()
+}
+
+func TestComposite_ClosesLimitersItDrops(t *testing.T) {
+	clock := NewFakeClock(nextHour())
+	built := make(map[time.Duration]*closeRecordingLimiter)
+	build := func(_ string, def LimitConfig, overrides map[string]LimitConfig) Limiter {
+		l := &closeRecordingLimiter{TokenBucketLimiter: NewTokenBucketLimiter(clock, def, overrides)}
+		built[def.Window] = l
+		return l
+	}
+
+	limiter := NewCompositeLimiter(build, clock, []LimitConfig{
+		{Limit: 2, Window: time.Second},
+		{Limit: 3, Window: time.Hour},
+	}, nil)
+
+	limiter.SetPolicy([]LimitConfig{{Limit: 5, Window: time.Hour}}, nil)
+	if !built[time.Second].closed {
+		t.Fatalf("expected the dropped per-second limiter to be closed")
+	}
+	if built[time.Hour].closed {
+		t.Fatalf("expected the kept hourly limiter to stay open")
+	}
+
+	if err := limiter.Close(); err != nil {
+		t.Fatalf("expected Close to succeed, got %v", err)
+	}
+	if !built[time.Hour].closed {
+		t.Fatalf("expected Close to close the hourly limiter")
+	}
+}
@@ -0,0 +1,167 @@
+package limiter
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bellettati/go-rate-limited-api/internal/store"
+)
+
+const defaultLeaseTTL = time.Minute
+
+// Slots are leases that expire after leaseTTL, so a replica that crashes
+// mid-request cannot leak them. Keys with a Limit of 0 are not capped.
+type ConcurrencyLimiter struct {
+	st       store.Store
+	clock    Clock
+	limits   *Limits
+	leaseTTL time.Duration
+}
+
+func NewConcurrencyLimiter(st store.Store, clock Clock, defaultLimit LimitConfig, overrides map[string]LimitConfig, leaseTTL time.Duration) *ConcurrencyLimiter {
+	if leaseTTL <= 0 {
+		leaseTTL = defaultLeaseTTL
+	}
+
+	return &ConcurrencyLimiter{
+		st:       st,
+		clock:    clock,
+		limits:   NewLimits(defaultLimit, overrides),
+		leaseTTL: leaseTTL,
+	}
+}
+
+func (c *ConcurrencyLimiter) SetLimits(defaultLimit LimitConfig, overrides map[string]LimitConfig) {
+	c.limits.Set(defaultLimit, overrides)
+}
+
+type Lease struct {
+	result  RateLimitResult
+	renew   func(ctx context.Context) error
+	release func(ctx context.Context) error
+	clock   Clock
+	ttl     time.Duration
+
+	mu       sync.Mutex
+	released bool
+	done     chan struct{}
+}
+
+func (l *Lease) OK() bool {
+	return l.result.Allowed
+}
+
+func (l *Lease) Result() RateLimitResult {
+	return l.result
+}
+
+func (l *Lease) Release(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.released || l.release == nil {
+		return nil
+	}
+	l.released = true
+	close(l.done)
+
+	return l.release(ctx)
+}
+
+// Failed renewals are retried on the next tick.
+func (l *Lease) KeepAlive(onError func(error)) {
+	if l.renew == nil {
+		return
+	}
+
+	every := l.ttl / 2
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-l.clock.After(every):
+		}
+
+		if err := l.renewOnce(every); err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
+
+func (l *Lease) renewOnce(timeout time.Duration) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.released {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return l.renew(ctx)
+}
+
+func (c *ConcurrencyLimiter) Acquire(ctx context.Context, apiKey string) (*Lease, error) {
+	limit := c.limits.For(apiKey).Limit
+	if limit <= 0 {
+		return &Lease{result: RateLimitResult{Allowed: true}}, nil
+	}
+
+	now := c.clock.Now()
+
+	id, err := newLeaseID()
+	if err != nil {
+		return nil, err
+	}
+
+	key := concurrencyKey(apiKey)
+	acquired, held, err := c.st.AcquireLease(ctx, key, id, int64(limit), now, c.leaseTTL)
+	if err != nil {
+		return nil, fmt.Errorf("concurrency acquire: %w", err)
+	}
+
+	res := RateLimitResult{
+		Allowed:   acquired,
+		Remaining: max(limit-int(held), 0),
+		ResetAt:   now.Add(c.leaseTTL),
+		Limit:     limit,
+	}
+	if !acquired {
+		return &Lease{result: res}, nil
+	}
+
+	return &Lease{
+		result: res,
+		renew: func(ctx context.Context) error {
+			_, _, err := c.st.AcquireLease(ctx, key, id, int64(limit), c.clock.Now(), c.leaseTTL)
+			if err != nil {
+				return fmt.Errorf("concurrency renew: %w", err)
+			}
+			return nil
+		},
+		release: func(ctx context.Context) error {
+			return c.st.ReleaseLease(ctx, key, id)
+		},
+		clock: c.clock,
+		ttl:   c.leaseTTL,
+		done:  make(chan struct{}),
+	}, nil
+}
+
+func concurrencyKey(apiKey string) string {
+	return "rl:inflight:" + apiKey
+}
+
+func newLeaseID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("lease id: %w", err)
+	}
+
+	return hex.EncodeToString(b), nil
+}
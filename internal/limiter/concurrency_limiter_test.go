@@ -0,0 +1,153 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bellettati/go-rate-limited-api/internal/store"
+)
+
+func TestConcurrency_CapsInFlightRequests(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	st := store.NewMemoryStoreWithCleanupInterval(time.Minute)
+	limiter := NewConcurrencyLimiter(st, clock, LimitConfig{Limit: 2}, nil, time.Minute)
+	ctx := context.Background()
+
+	first, err := limiter.Acquire(ctx, "test-key")
+	if err != nil || !first.OK() {
+		t.Fatalf("expected first lease, got ok=%v err=%v", first.OK(), err)
+	}
+
+	second, _ := limiter.Acquire(ctx, "test-key")
+	if !second.OK() || second.Result().Remaining != 0 {
+		t.Fatalf("expected second lease with no slots left, got %+v", second.Result())
+	}
+
+	third, _ := limiter.Acquire(ctx, "test-key")
+	if third.OK() {
+		t.Fatalf("expected third concurrent request to be denied")
+	}
+
+	if other, _ := limiter.Acquire(ctx, "other-key"); !other.OK() {
+		t.Fatalf("expected other keys to have their own slots")
+	}
+
+	if err := first.Release(ctx); err != nil {
+		t.Fatalf("unexpected release error: %v", err)
+	}
+
+	if again, _ := limiter.Acquire(ctx, "test-key"); !again.OK() {
+		t.Fatalf("expected released slot to be reusable")
+	}
+}
+
+func TestConcurrency_ReleaseIsIdempotent(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	st := store.NewMemoryStoreWithCleanupInterval(time.Minute)
+	limiter := NewConcurrencyLimiter(st, clock, LimitConfig{Limit: 1}, nil, time.Minute)
+	ctx := context.Background()
+
+	lease, _ := limiter.Acquire(ctx, "test-key")
+	lease.Release(ctx)
+	lease.Release(ctx)
+
+	limiter.Acquire(ctx, "test-key")
+	if res, _ := limiter.Acquire(ctx, "test-key"); res.OK() {
+		t.Fatalf("expected a double release not to free an extra slot")
+	}
+}
+
+func TestConcurrency_ExpiredLeasesFreeSlots(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	st := store.NewMemoryStoreWithCleanupInterval(time.Minute)
+	limiter := NewConcurrencyLimiter(st, clock, LimitConfig{Limit: 1}, nil, 30*time.Second)
+	ctx := context.Background()
+
+	limiter.Acquire(ctx, "test-key")
+
+	if lease, _ := limiter.Acquire(ctx, "test-key"); lease.OK() {
+		t.Fatalf("expected slot to be held")
+	}
+
+	clock.Advance(30 * time.Second)
+
+	if lease, _ := limiter.Acquire(ctx, "test-key"); !lease.OK() {
+		t.Fatalf("expected lease of a vanished holder to expire")
+	}
+}
+
+func TestConcurrency_Overrides(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	st := store.NewMemoryStoreWithCleanupInterval(time.Minute)
+	limiter := NewConcurrencyLimiter(st, clock, LimitConfig{Limit: 1}, map[string]LimitConfig{"vip": {Limit: 3}}, time.Minute)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if lease, _ := limiter.Acquire(ctx, "vip"); !lease.OK() {
+			t.Fatalf("expected vip request %d to get a slot", i+1)
+		}
+	}
+
+	if lease, _ := limiter.Acquire(ctx, "vip"); lease.OK() {
+		t.Fatalf("expected vip to be capped at 3")
+	}
+}
+
+func TestConcurrency_KeepAliveHoldsTheSlotPastTheTTL(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	st := store.NewMemoryStoreWithCleanupInterval(time.Minute)
+	limiter := NewConcurrencyLimiter(st, clock, LimitConfig{Limit: 1}, nil, 30*time.Second)
+	ctx := context.Background()
+
+	lease, _ := limiter.Acquire(ctx, "test-key")
+	stopped := make(chan struct{})
+	go func() {
+		lease.KeepAlive(func(err error) { t.Errorf("unexpected renew error: %v", err) })
+		close(stopped)
+	}()
+
+	for i := 0; i < 4; i++ {
+		for clock.Waiters() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		clock.Advance(15 * time.Second)
+	}
+	for clock.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if other, _ := limiter.Acquire(ctx, "test-key"); other.OK() {
+		t.Fatalf("expected a renewed lease to keep its slot past the TTL")
+	}
+
+	lease.Release(ctx)
+	<-stopped
+
+	if other, _ := limiter.Acquire(ctx, "test-key"); !other.OK() {
+		t.Fatalf("expected release to free the slot")
+	}
+}
+
+func TestConcurrency_SetLimitsChangesTheCap(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	st := store.NewMemoryStoreWithCleanupInterval(time.Minute)
+	limiter := NewConcurrencyLimiter(st, clock, LimitConfig{Limit: 1}, nil, time.Minute)
+	ctx := context.Background()
+
+	limiter.Acquire(ctx, "test-key")
+	if lease, _ := limiter.Acquire(ctx, "test-key"); lease.OK() {
+		t.Fatalf("expected the second request to be capped at 1")
+	}
+
+	limiter.SetLimits(LimitConfig{Limit: 2}, nil)
+	if lease, _ := limiter.Acquire(ctx, "test-key"); !lease.OK() {
+		t.Fatalf("expected the raised cap to apply to the next request")
+	}
+
+	limiter.SetLimits(LimitConfig{Limit: 1}, map[string]LimitConfig{"test-key": {}})
+	lease, _ := limiter.Acquire(ctx, "test-key")
+	if !lease.OK() || lease.Result().Limit != 0 {
+		t.Fatalf("expected a key without a cap to go through uncapped, got %+v", lease.Result())
+	}
+}
@@ -0,0 +1,152 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bellettati/go-rate-limited-api/internal/store"
+)
+
+type DistributedTokenBucketLimiter struct {
+	st     store.Store
+	limits *Limits
+	clock  Clock
+}
+
+func NewDistributedTokenBucketLimiter(st store.Store, clock Clock, defaultLimit LimitConfig, overrides map[string]LimitConfig) *DistributedTokenBucketLimiter {
+	return &DistributedTokenBucketLimiter{
+		st:     st,
+		limits: NewLimits(defaultLimit, overrides),
+		clock:  clock,
+	}
+}
+
+func (tb *DistributedTokenBucketLimiter) configFor(apiKey string) LimitConfig {
+	return tb.limits.For(apiKey)
+}
+
+func (tb *DistributedTokenBucketLimiter) SetLimits(defaultLimit LimitConfig, overrides map[string]LimitConfig) {
+	tb.limits.Set(defaultLimit, overrides)
+}
+
+func (tb *DistributedTokenBucketLimiter) Allow(apiKey string) RateLimitResult {
+	res, err := tb.AllowN(context.Background(), apiKey, 1)
+	if err != nil {
+		return failOpen(res)
+	}
+
+	return res
+}
+
+func (tb *DistributedTokenBucketLimiter) AllowN(ctx context.Context, apiKey string, n int) (RateLimitResult, error) {
+	return tb.take(ctx, apiKey, normalizeCost(n))
+}
+
+func (tb *DistributedTokenBucketLimiter) Reserve(ctx context.Context, apiKey string) (*Reservation, error) {
+	return tb.ReserveN(ctx, apiKey, 1)
+}
+
+func (tb *DistributedTokenBucketLimiter) ReserveN(ctx context.Context, apiKey string, n int) (*Reservation, error) {
+	n = normalizeCost(n)
+
+	res, err := tb.take(ctx, apiKey, n)
+	if err != nil {
+		return nil, err
+	}
+
+	return newReservation(res, 0, func(ctx context.Context) error {
+		_, err := tb.take(ctx, apiKey, -n)
+		return err
+	}), nil
+}
+
+func (tb *DistributedTokenBucketLimiter) Wait(ctx context.Context, apiKey string) error {
+	return tb.WaitN(ctx, apiKey, 1)
+}
+
+func (tb *DistributedTokenBucketLimiter) WaitN(ctx context.Context, apiKey string, n int) error {
+	return waitN(ctx, tb, tb.clock, apiKey, n)
+}
+
+func (tb *DistributedTokenBucketLimiter) take(ctx context.Context, apiKey string, n int) (RateLimitResult, error) {
+	cfg := tb.configFor(apiKey)
+	now := tb.clock.Now()
+
+	capacity := float64(cfg.Limit)
+	refillRate := capacity / cfg.Window.Seconds()
+
+	// An idle bucket is full again after one window, so its state can expire.
+	allowed, tokens, err := tb.st.TakeToken(ctx, bucketKey(apiKey), float64(n), capacity, refillRate, now, cfg.Window)
+	if err != nil {
+		return RateLimitResult{ResetAt: now.Add(cfg.Window), Limit: cfg.Limit, Window: cfg.Window}, fmt.Errorf("token bucket take: %w", err)
+	}
+
+	resetAt := now.Add(secondsToDuration((capacity - tokens) / refillRate))
+	var retryAfter time.Duration
+	if !allowed {
+		retryAfter = secondsToDuration((float64(n) - tokens) / refillRate)
+		resetAt = now.Add(retryAfter)
+	}
+
+	return RateLimitResult{
+		Allowed:    allowed,
+		Remaining:  int(tokens),
+		Limit:      cfg.Limit,
+		Window:     cfg.Window,
+		ResetAt:    resetAt,
+		RetryAfter: retryAfter,
+	}, nil
+}
+
+func bucketKey(apiKey string) string {
+	return "rl:bucket:" + apiKey
+}
+
+func (tb *DistributedTokenBucketLimiter) Inspect(ctx context.Context, apiKey string) (KeyState, error) {
+	cfg := tb.configFor(apiKey)
+	now := tb.clock.Now()
+
+	capacity := float64(cfg.Limit)
+	refillRate := capacity / cfg.Window.Seconds()
+
+	tokens, updatedAt, found, err := tb.st.GetBucket(ctx, bucketKey(apiKey))
+	if err != nil {
+		return KeyState{}, fmt.Errorf("token bucket get: %w", err)
+	}
+
+	if !found {
+		tokens = capacity
+	} else if now.After(updatedAt) {
+		tokens = min(capacity, tokens+refillRate*now.Sub(updatedAt).Seconds())
+	}
+
+	return bucketState(apiKey, cfg, tokens, now), nil
+}
+
+func (tb *DistributedTokenBucketLimiter) Reset(ctx context.Context, apiKey string) error {
+	return tb.st.Delete(ctx, bucketKey(apiKey))
+}
+
+func (tb *DistributedTokenBucketLimiter) TopKeys(ctx context.Context, n int, owns func(apiKey string) bool) ([]KeyState, error) {
+	return topStoredKeys(ctx, tb.st, "rl:bucket:", n, owns, sameKey, tb.Inspect)
+}
+
+func bucketState(apiKey string, cfg LimitConfig, tokens float64, now time.Time) KeyState {
+	capacity := float64(cfg.Limit)
+	refillRate := capacity / cfg.Window.Seconds()
+
+	return KeyState{
+		Key:       apiKey,
+		Limit:     cfg.Limit,
+		Window:    cfg.Window,
+		Used:      capacity - tokens,
+		Remaining: int(tokens),
+		ResetAt:   now.Add(secondsToDuration((capacity - tokens) / refillRate)),
+		Tokens:    &tokens,
+	}
+}
+
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,86 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bellettati/go-rate-limited-api/internal/store"
+)
+
+func TestDistributedTokenBucketAllowsInitialBurst(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	st := store.NewMemoryStoreWithCleanupInterval(time.Minute)
+	limiter := NewDistributedTokenBucketLimiter(st, clock, LimitConfig{Limit: 2, Window: time.Minute}, nil)
+
+	res1 := limiter.Allow("test-key")
+	res2 := limiter.Allow("test-key")
+	res3 := limiter.Allow("test-key")
+
+	if !res1.Allowed || !res2.Allowed {
+		t.Fatalf("expected first two requests allowed")
+	}
+
+	if res3.Allowed {
+		t.Fatalf("expected third request to be denied")
+	}
+
+	if want := clock.Now().Add(30 * time.Second); !res3.ResetAt.Equal(want) {
+		t.Fatalf("expected next token at %v, got %v", want, res3.ResetAt)
+	}
+}
+
+func TestDistributedTokenBucketPartialRefill(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	st := store.NewMemoryStoreWithCleanupInterval(time.Minute)
+	limiter := NewDistributedTokenBucketLimiter(st, clock, LimitConfig{Limit: 10, Window: 10 * time.Second}, nil)
+
+	for i := 0; i < 10; i++ {
+		limiter.Allow("test-key")
+	}
+
+	if res := limiter.Allow("test-key"); res.Allowed {
+		t.Fatalf("expected empty bucket to deny")
+	}
+
+	clock.Advance(time.Second)
+
+	if res := limiter.Allow("test-key"); !res.Allowed {
+		t.Fatalf("expected partial refill to allow request")
+	}
+}
+
+func TestDistributedTokenBucketDoesNotOverfill(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	st := store.NewMemoryStoreWithCleanupInterval(time.Minute)
+	limiter := NewDistributedTokenBucketLimiter(st, clock, LimitConfig{Limit: 2, Window: time.Second}, nil)
+
+	limiter.Allow("test-key")
+	clock.Advance(5 * time.Second)
+
+	res1 := limiter.Allow("test-key")
+	res2 := limiter.Allow("test-key")
+	res3 := limiter.Allow("test-key")
+
+	if !res1.Allowed || !res2.Allowed {
+		t.Fatalf("expected full bucket")
+	}
+
+	if res3.Allowed {
+		t.Fatalf("bucket should not exceed capacity")
+	}
+}
+
+func TestDistributedTokenBucketSharedAcrossInstances(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	st := store.NewMemoryStoreWithCleanupInterval(time.Minute)
+	cfg := LimitConfig{Limit: 1, Window: time.Minute}
+
+	a := NewDistributedTokenBucketLimiter(st, clock, cfg, nil)
+	b := NewDistributedTokenBucketLimiter(st, clock, cfg, nil)
+
+	a.Allow("test-key")
+
+	if res := b.Allow("test-key"); res.Allowed {
+		t.Fatalf("expected limiters sharing a store to share the bucket")
+	}
+}
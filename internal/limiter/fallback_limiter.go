@@ -0,0 +1,117 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+)
+
+type FallbackLimiter struct {
+	primary  Limiter
+	fallback Limiter
+	clock    Clock
+}
+
+func NewFallbackLimiter(primary Limiter, fallback Limiter, clock Clock) *FallbackLimiter {
+	return &FallbackLimiter{primary: primary, fallback: fallback, clock: clock}
+}
+
+func (f *FallbackLimiter) Allow(apiKey string) RateLimitResult {
+	res, err := f.AllowN(context.Background(), apiKey, 1)
+	if err != nil {
+		return failOpen(res)
+	}
+
+	return res
+}
+
+func (f *FallbackLimiter) AllowN(ctx context.Context, apiKey string, n int) (RateLimitResult, error) {
+	res, err := f.primary.AllowN(ctx, apiKey, n)
+	if err == nil {
+		return res, nil
+	}
+
+	// The request itself is gone; there is nothing to fall back for.
+	if ctx.Err() != nil {
+		return res, err
+	}
+
+	fres, ferr := f.fallback.AllowN(ctx, apiKey, n)
+	if ferr != nil {
+		return res, fmt.Errorf("%w (fallback: %v)", err, ferr)
+	}
+
+	fres.Fallback = true
+	return fres, nil
+}
+
+// Cancelling returns units to whichever limiter granted them.
+func (f *FallbackLimiter) ReserveN(ctx context.Context, apiKey string, n int) (*Reservation, error) {
+	r, err := Reserve(ctx, f.primary, apiKey, n)
+	if err == nil {
+		return r, nil
+	}
+
+	if ctx.Err() != nil {
+		return nil, err
+	}
+
+	fr, ferr := Reserve(ctx, f.fallback, apiKey, n)
+	if ferr != nil {
+		return nil, fmt.Errorf("%w (fallback: %v)", err, ferr)
+	}
+
+	fr.result.Fallback = true
+	return fr, nil
+}
+
+func (f *FallbackLimiter) Reserve(ctx context.Context, apiKey string) (*Reservation, error) {
+	return f.ReserveN(ctx, apiKey, 1)
+}
+
+func (f *FallbackLimiter) Wait(ctx context.Context, apiKey string) error {
+	return f.WaitN(ctx, apiKey, 1)
+}
+
+func (f *FallbackLimiter) WaitN(ctx context.Context, apiKey string, n int) error {
+	return waitN(ctx, f, f.clock, apiKey, n)
+}
+
+// Limits never drop below 1.
+func ScaleLimits(defaultLimit LimitConfig, overrides map[string]LimitConfig, replicas int) (LimitConfig, map[string]LimitConfig) {
+	scaled := make(map[string]LimitConfig, len(overrides))
+	for key, cfg := range overrides {
+		scaled[key] = scaleLimit(cfg, replicas)
+	}
+
+	return scaleLimit(defaultLimit, replicas), scaled
+}
+
+func scaleLimit(cfg LimitConfig, replicas int) LimitConfig {
+	if replicas <= 1 {
+		return cfg
+	}
+
+	cfg.Limit /= replicas
+	if cfg.Limit < 1 {
+		cfg.Limit = 1
+	}
+
+	return cfg
+}
+
+func ScalePolicy(defaultPolicy []LimitConfig, overrides map[string][]LimitConfig, replicas int) ([]LimitConfig, map[string][]LimitConfig) {
+	scaleAll := func(policy []LimitConfig) []LimitConfig {
+		scaled := make([]LimitConfig, 0, len(policy))
+		for _, cfg := range policy {
+			scaled = append(scaled, scaleLimit(cfg, replicas))
+		}
+		return scaled
+	}
+
+	scaled := make(map[string][]LimitConfig, len(overrides))
+	for key, policy := range overrides {
+		scaled[key] = scaleAll(policy)
+	}
+
+	return scaleAll(defaultPolicy), scaled
+}
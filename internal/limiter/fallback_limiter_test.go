@@ -0,0 +1,96 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bellettati/go-rate-limited-api/internal/store"
+)
+
+type flakyStore struct {
+	*store.MemoryStore
+	down  bool
+	calls int
+}
+
+func (f *flakyStore) FixedWindowIncr(ctx context.Context, key string, cost int64, limit int64, ttl time.Duration) (bool, int64, error) {
+	f.calls++
+	if f.down {
+		return false, 0, errors.New("connection refused")
+	}
+
+	return f.MemoryStore.FixedWindowIncr(ctx, key, cost, limit, ttl)
+}
+
+func TestFallbackLimiter_UsesFallbackWhenPrimaryFails(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	primary := NewFixedWindowLimiter(&flakyStore{MemoryStore: store.NewMemoryStore(), down: true}, clock, LimitConfig{Limit: 10, Window: time.Minute}, nil)
+	fallback := NewFixedWindowLimiter(store.NewMemoryStore(), clock, LimitConfig{Limit: 1, Window: time.Minute}, nil)
+
+	rl := NewFallbackLimiter(primary, fallback, clock)
+
+	res, err := rl.AllowN(context.Background(), "test-key", 1)
+	if err != nil || !res.Allowed || !res.Fallback {
+		t.Fatalf("expected fallback decision, got %+v err=%v", res, err)
+	}
+
+	if res, _ := rl.AllowN(context.Background(), "test-key", 1); res.Allowed {
+		t.Fatalf("expected fallback limit to be enforced")
+	}
+}
+
+func TestFallbackLimiter_BreakerSkipsStoreAndRecovers(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	flaky := &flakyStore{MemoryStore: store.NewMemoryStore(), down: true}
+	breaker := store.NewBreakerStore(flaky, store.BreakerConfig{
+		FailureThreshold: 2,
+		OpenTimeout:      10 * time.Second,
+		Now:              clock.Now,
+	})
+
+	primary := NewFixedWindowLimiter(breaker, clock, LimitConfig{Limit: 10, Window: time.Minute}, nil)
+	fallback := NewFixedWindowLimiter(store.NewMemoryStore(), clock, LimitConfig{Limit: 10, Window: time.Minute}, nil)
+	rl := NewFallbackLimiter(primary, fallback, clock)
+
+	for i := 0; i < 5; i++ {
+		rl.AllowN(context.Background(), "test-key", 1)
+	}
+
+	if flaky.calls != 2 {
+		t.Fatalf("expected breaker to stop calling the store after 2 failures, got %d calls", flaky.calls)
+	}
+
+	if breaker.State() != store.BreakerOpen {
+		t.Fatalf("expected breaker to be open, got %s", breaker.State())
+	}
+
+	flaky.down = false
+	clock.Advance(10 * time.Second)
+
+	res, err := rl.AllowN(context.Background(), "test-key", 1)
+	if err != nil || res.Fallback {
+		t.Fatalf("expected trial call to reach the recovered store, got %+v err=%v", res, err)
+	}
+
+	if breaker.State() != store.BreakerClosed {
+		t.Fatalf("expected breaker to close after a successful trial, got %s", breaker.State())
+	}
+}
+
+func TestScaleLimits_DividesByReplicas(t *testing.T) {
+	def, overrides := ScaleLimits(
+		LimitConfig{Limit: 100, Window: time.Minute},
+		map[string]LimitConfig{"vip": {Limit: 2, Window: time.Minute}},
+		3,
+	)
+
+	if def.Limit != 33 {
+		t.Fatalf("expected default limit 33, got %d", def.Limit)
+	}
+
+	if overrides["vip"].Limit != 1 {
+		t.Fatalf("expected scaled limits to stay at least 1, got %d", overrides["vip"].Limit)
+	}
+}
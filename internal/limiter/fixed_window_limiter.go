@@ -2,6 +2,7 @@ package limiter
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/bellettati/go-rate-limited-api/internal/store"
@@ -9,30 +10,24 @@ import (
 
 type FixedWindowLimiter struct {
 	st 			 store.Store
-	defaultLimit LimitConfig
-	overrides    map[string]LimitConfig
+	limits       *Limits
 	clock 		 Clock
 }
 
 func NewFixedWindowLimiter(st store.Store, clock Clock, defaultLimit LimitConfig, overrides map[string]LimitConfig) *FixedWindowLimiter {
-	if overrides == nil {
-		overrides = make(map[string]LimitConfig)
-	}
-
 	return &FixedWindowLimiter{
 		st:		      st,
-		defaultLimit: defaultLimit,
-		overrides:    overrides,
+		limits:       NewLimits(defaultLimit, overrides),
 		clock:        clock,
 	}
 }
 
 func (rl *FixedWindowLimiter) configFor(apiKey string) LimitConfig {
-	if cfg, ok := rl.overrides[apiKey]; ok {
-		return cfg
-	}
+	return rl.limits.For(apiKey)
+}
 
-	return rl.defaultLimit
+func (rl *FixedWindowLimiter) SetLimits(defaultLimit LimitConfig, overrides map[string]LimitConfig) {
+	rl.limits.Set(defaultLimit, overrides)
 }
 
 func windowBounds(now time.Time, window time.Duration) (start time.Time, end time.Time) {
@@ -42,41 +37,90 @@ func windowBounds(now time.Time, window time.Duration) (start time.Time, end tim
 }
 
 func (rl *FixedWindowLimiter) Allow(apiKey string) RateLimitResult {
-	cfg := rl.configFor(apiKey)
+	res, err := rl.AllowN(context.Background(), apiKey, 1)
+	if err != nil {
+		return failOpen(res)
+	}
+
+	return res
+}
+
+func (rl *FixedWindowLimiter) AllowN(ctx context.Context, apiKey string, n int) (RateLimitResult, error) {
+	return rl.allowN(ctx, apiKey, normalizeCost(n), rl.clock.Now())
+}
+
+func (rl *FixedWindowLimiter) Reserve(ctx context.Context, apiKey string) (*Reservation, error) {
+	return rl.ReserveN(ctx, apiKey, 1)
+}
 
+func (rl *FixedWindowLimiter) ReserveN(ctx context.Context, apiKey string, n int) (*Reservation, error) {
+	n = normalizeCost(n)
 	now := rl.clock.Now()
+
+	res, err := rl.allowN(ctx, apiKey, n, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return newReservation(res, 0, func(ctx context.Context) error {
+		windowStart, windowEnd := windowBounds(now, res.Window)
+		if !rl.clock.Now().Before(windowEnd) {
+			return nil
+		}
+
+		_, _, err := rl.st.IncrByWithTTL(ctx, fixedWindowKey(apiKey, windowStart), -int64(n), time.Until(windowEnd))
+		return err
+	}), nil
+}
+
+func (rl *FixedWindowLimiter) Wait(ctx context.Context, apiKey string) error {
+	return rl.WaitN(ctx, apiKey, 1)
+}
+
+func (rl *FixedWindowLimiter) WaitN(ctx context.Context, apiKey string, n int) error {
+	return waitN(ctx, rl, rl.clock, apiKey, n)
+}
+
+func fixedWindowKey(apiKey string, windowStart time.Time) string {
+	return "rl:fixed:"+apiKey+":"+formatUnixNano(windowStart)
+}
+
+func (rl *FixedWindowLimiter) allowN(ctx context.Context, apiKey string, n int, now time.Time) (RateLimitResult, error) {
+	cfg := rl.configFor(apiKey)
+
 	windowStart, windowEnd := windowBounds(now, cfg.Window)
 
-	key := "rl:fixed:"+apiKey+":"+formatUnixNano(windowStart)
+	key := fixedWindowKey(apiKey, windowStart)
 
 	ttl := time.Until(windowEnd)
 	if ttl < 0 {
 		ttl = 0
 	}
 
-	val, _, err := rl.st.IncrWithTTL(context.Background(), key, ttl)
+	allowed, val, err := rl.st.FixedWindowIncr(ctx, key, int64(n), int64(cfg.Limit), ttl)
 	if err != nil {
-		return RateLimitResult {
-			Allowed:   true,
-			Remaining: cfg.Limit,
-			ResetAt:   windowEnd,
-			Limit:     cfg.Limit,
-		}
+		return RateLimitResult{ResetAt: windowEnd, Limit: cfg.Limit, Window: cfg.Window}, fmt.Errorf("fixed window incr: %w", err)
 	}
 
-	allowed := int(val) <= cfg.Limit
 	remaining := cfg.Limit - int(val)
 
 	if remaining < 0 {
 		remaining = 0
 	}
 
-	return RateLimitResult{
-		Allowed:   allowed,
-		Remaining: remaining,
-		ResetAt:   windowEnd,
-		Limit:     cfg.Limit,
+	var retryAfter time.Duration
+	if !allowed {
+		retryAfter = windowEnd.Sub(now)
 	}
+
+	return RateLimitResult{
+		Allowed:    allowed,
+		Remaining:  remaining,
+		ResetAt:    windowEnd,
+		Limit:      cfg.Limit,
+		Window:     cfg.Window,
+		RetryAfter: retryAfter,
+	}, nil
 }
 
 func formatUnixNano(t time.Time) string {
@@ -107,4 +151,37 @@ func formatUnixNano(t time.Time) string {
 	}
 
 	return string(b[i:])
-}
\ No newline at end of file
+}
+func (rl *FixedWindowLimiter) Inspect(ctx context.Context, apiKey string) (KeyState, error) {
+	cfg := rl.configFor(apiKey)
+	now := rl.clock.Now()
+	windowStart, windowEnd := windowBounds(now, cfg.Window)
+
+	count, _, err := rl.st.Get(ctx, fixedWindowKey(apiKey, windowStart))
+	if err != nil {
+		return KeyState{}, fmt.Errorf("fixed window get: %w", err)
+	}
+
+	resetAt := now
+	if count > 0 {
+		resetAt = windowEnd
+	}
+
+	return KeyState{
+		Key:       apiKey,
+		Limit:     cfg.Limit,
+		Window:    cfg.Window,
+		Used:      float64(count),
+		Remaining: max(cfg.Limit-int(count), 0),
+		ResetAt:   resetAt,
+	}, nil
+}
+
+func (rl *FixedWindowLimiter) Reset(ctx context.Context, apiKey string) error {
+	windowStart, _ := windowBounds(rl.clock.Now(), rl.configFor(apiKey).Window)
+	return rl.st.Delete(ctx, fixedWindowKey(apiKey, windowStart))
+}
+
+func (rl *FixedWindowLimiter) TopKeys(ctx context.Context, n int, owns func(apiKey string) bool) ([]KeyState, error) {
+	return topStoredKeys(ctx, rl.st, "rl:fixed:", n, owns, trimWindowStart, rl.Inspect)
+}
@@ -1,6 +1,8 @@
 package limiter
 
 import (
+	"context"
+	"errors"
 	"sync"
 	"testing"
 	"time"
@@ -103,3 +105,123 @@ func TestAllow_OverrideTakesPrecedence(t *testing.T) {
 		t.Fatalf("expected override limit to be enforced")
 	}
 }
+
+func TestAllowN_ChargesCost(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	st := store.NewMemoryStoreWithCleanupInterval(time.Minute)
+	rl := NewFixedWindowLimiter(st, clock, LimitConfig{Limit: 5, Window: time.Minute}, nil)
+
+	res, _ := rl.AllowN(context.Background(), "test-key", 3)
+	if !res.Allowed || res.Remaining != 2 {
+		t.Fatalf("expected cost of 3 to leave 2 remaining, got %+v", res)
+	}
+}
+
+func TestAllowN_DeniedCostIsNotCharged(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	st := store.NewMemoryStoreWithCleanupInterval(time.Minute)
+	rl := NewFixedWindowLimiter(st, clock, LimitConfig{Limit: 5, Window: time.Minute}, nil)
+
+	rl.AllowN(context.Background(), "test-key", 3)
+
+	if res, _ := rl.AllowN(context.Background(), "test-key", 3); res.Allowed {
+		t.Fatalf("expected request exceeding remaining budget to be denied")
+	}
+
+	res, _ := rl.AllowN(context.Background(), "test-key", 2)
+	if !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("expected cheaper request to use the remaining budget, got %+v", res)
+	}
+}
+
+func TestAllowN_DeniedCostNeverBlocksConcurrentCallers(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	st := store.NewMemoryStoreWithCleanupInterval(time.Minute)
+	rl := NewFixedWindowLimiter(st, clock, LimitConfig{Limit: 10, Window: time.Minute}, nil)
+
+	rl.AllowN(context.Background(), "test-key", 9)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rl.AllowN(context.Background(), "test-key", 5)
+		}()
+	}
+
+	res, _ := rl.AllowN(context.Background(), "test-key", 1)
+	wg.Wait()
+
+	if !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("expected the last unit to be allowed while larger requests are denied, got %+v", res)
+	}
+}
+
+type failingStore struct {
+	store.Store
+	err error
+}
+
+func (f failingStore) IncrByWithTTL(ctx context.Context, _ string, _ int64, _ time.Duration) (int64, time.Duration, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	return 0, 0, f.err
+}
+
+func (f failingStore) FixedWindowIncr(ctx context.Context, _ string, _ int64, _ int64, _ time.Duration) (bool, int64, error) {
+	if err := ctx.Err(); err != nil {
+		return false, 0, err
+	}
+
+	return false, 0, f.err
+}
+
+func TestAllowN_SurfacesStoreErrors(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	storeErr := errors.New("store unavailable")
+	rl := NewFixedWindowLimiter(failingStore{err: storeErr}, clock, LimitConfig{Limit: 5, Window: time.Minute}, nil)
+
+	res, err := rl.AllowN(context.Background(), "test-key", 1)
+	if !errors.Is(err, storeErr) {
+		t.Fatalf("expected store error, got %v", err)
+	}
+
+	if res.Allowed || res.Limit != 5 {
+		t.Fatalf("expected undecided result carrying the limit, got %+v", res)
+	}
+
+	if legacy := rl.Allow("test-key"); !legacy.Allowed || legacy.Remaining != 5 {
+		t.Fatalf("expected legacy Allow to fail open, got %+v", legacy)
+	}
+}
+
+func TestAllowN_PropagatesContext(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	rl := NewFixedWindowLimiter(failingStore{}, clock, LimitConfig{Limit: 5, Window: time.Minute}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := rl.AllowN(ctx, "test-key", 1); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation to reach the store, got %v", err)
+	}
+}
+
+func TestFromLegacy_AdaptsOldLimiters(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	old := NewTokenBucketLimiter(clock, LimitConfig{Limit: 2, Window: time.Minute}, nil)
+	var legacy LegacyLimiter = old
+
+	rl := FromLegacy(legacy)
+
+	if res, err := rl.AllowN(context.Background(), "test-key", 2); err != nil || !res.Allowed {
+		t.Fatalf("expected adapted limiter to allow, got %+v err=%v", res, err)
+	}
+
+	if res, _ := rl.AllowN(context.Background(), "test-key", 1); res.Allowed {
+		t.Fatalf("expected adapted limiter to enforce the limit")
+	}
+}
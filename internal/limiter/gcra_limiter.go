@@ -0,0 +1,142 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/bellettati/go-rate-limited-api/internal/store"
+)
+
+type GCRALimiter struct {
+	st     store.Store
+	limits *Limits
+	clock  Clock
+}
+
+func NewGCRALimiter(st store.Store, clock Clock, defaultLimit LimitConfig, overrides map[string]LimitConfig) *GCRALimiter {
+	return &GCRALimiter{
+		st:     st,
+		limits: NewLimits(defaultLimit, overrides),
+		clock:  clock,
+	}
+}
+
+func (g *GCRALimiter) configFor(apiKey string) LimitConfig {
+	return g.limits.For(apiKey)
+}
+
+func (g *GCRALimiter) SetLimits(defaultLimit LimitConfig, overrides map[string]LimitConfig) {
+	g.limits.Set(defaultLimit, overrides)
+}
+
+func (g *GCRALimiter) Allow(apiKey string) RateLimitResult {
+	res, err := g.AllowN(context.Background(), apiKey, 1)
+	if err != nil {
+		return failOpen(res)
+	}
+
+	return res
+}
+
+func (g *GCRALimiter) Reserve(ctx context.Context, apiKey string) (*Reservation, error) {
+	return g.ReserveN(ctx, apiKey, 1)
+}
+
+func (g *GCRALimiter) ReserveN(ctx context.Context, apiKey string, n int) (*Reservation, error) {
+	n = normalizeCost(n)
+
+	res, err := g.AllowN(ctx, apiKey, n)
+	if err != nil {
+		return nil, err
+	}
+
+	return newReservation(res, 0, func(ctx context.Context) error {
+		interval := res.Window / time.Duration(res.Limit)
+
+		_, _, err := g.st.UpdateTAT(ctx, gcraKey(apiKey), g.clock.Now(), -interval*time.Duration(n), math.MaxInt64)
+		return err
+	}), nil
+}
+
+func (g *GCRALimiter) Wait(ctx context.Context, apiKey string) error {
+	return g.WaitN(ctx, apiKey, 1)
+}
+
+func (g *GCRALimiter) WaitN(ctx context.Context, apiKey string, n int) error {
+	return waitN(ctx, g, g.clock, apiKey, n)
+}
+
+func gcraKey(apiKey string) string {
+	return "rl:gcra:" + apiKey
+}
+
+// AllowN spaces units one emission interval (Window/Limit) apart while
+// letting the theoretical arrival time run up to a full Window ahead of now,
+// which permits a burst of Limit units.
+func (g *GCRALimiter) AllowN(ctx context.Context, apiKey string, n int) (RateLimitResult, error) {
+	n = normalizeCost(n)
+	cfg := g.configFor(apiKey)
+	now := g.clock.Now()
+
+	interval := cfg.Window / time.Duration(cfg.Limit)
+
+	allowed, tat, err := g.st.UpdateTAT(ctx, gcraKey(apiKey), now, interval*time.Duration(n), cfg.Window)
+	if err != nil {
+		return RateLimitResult{ResetAt: now.Add(cfg.Window), Limit: cfg.Limit, Window: cfg.Window}, fmt.Errorf("gcra update: %w", err)
+	}
+
+	if !allowed {
+		retryAfter := tat.Sub(now) - cfg.Window
+		return RateLimitResult{
+			Allowed:    false,
+			Remaining:  0,
+			ResetAt:    now.Add(retryAfter),
+			Limit:      cfg.Limit,
+			Window:     cfg.Window,
+			RetryAfter: retryAfter,
+		}, nil
+	}
+
+	remaining := int((cfg.Window - tat.Sub(now)) / interval)
+
+	return RateLimitResult{
+		Allowed:   true,
+		Remaining: remaining,
+		ResetAt:   tat,
+		Limit:     cfg.Limit,
+		Window:    cfg.Window,
+	}, nil
+}
+
+func (g *GCRALimiter) Inspect(ctx context.Context, apiKey string) (KeyState, error) {
+	cfg := g.configFor(apiKey)
+	now := g.clock.Now()
+	interval := cfg.Window / time.Duration(cfg.Limit)
+
+	tat, err := g.st.GetTAT(ctx, gcraKey(apiKey))
+	if err != nil {
+		return KeyState{}, fmt.Errorf("gcra get: %w", err)
+	}
+
+	state := KeyState{Key: apiKey, Limit: cfg.Limit, Window: cfg.Window, Remaining: cfg.Limit, ResetAt: now}
+	if ahead := tat.Sub(now); ahead > 0 {
+		state.Used = float64(ahead) / float64(interval)
+		state.Remaining = max(int((cfg.Window-ahead)/interval), 0)
+		state.ResetAt = tat
+	}
+	if !tat.IsZero() {
+		state.TAT = &tat
+	}
+
+	return state, nil
+}
+
+func (g *GCRALimiter) Reset(ctx context.Context, apiKey string) error {
+	return g.st.Delete(ctx, gcraKey(apiKey))
+}
+
+func (g *GCRALimiter) TopKeys(ctx context.Context, n int, owns func(apiKey string) bool) ([]KeyState, error) {
+	return topStoredKeys(ctx, g.st, "rl:gcra:", n, owns, sameKey, g.Inspect)
+}
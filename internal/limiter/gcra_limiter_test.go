@@ -0,0 +1,115 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bellettati/go-rate-limited-api/internal/store"
+)
+
+func TestGCRA_AllowsBurstUpToLimit(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	st := store.NewMemoryStoreWithCleanupInterval(time.Minute)
+	limiter := NewGCRALimiter(st, clock, LimitConfig{Limit: 3, Window: 3 * time.Second}, nil)
+
+	for i := 0; i < 3; i++ {
+		res := limiter.Allow("test-key")
+		if !res.Allowed {
+			t.Fatalf("expected request %d to be allowed", i+1)
+		}
+
+		if res.Remaining != 3-(i+1) {
+			t.Fatalf("expected remaining %d, got %d", 3-(i+1), res.Remaining)
+		}
+	}
+
+	res := limiter.Allow("test-key")
+	if res.Allowed {
+		t.Fatalf("expected request over burst to be denied")
+	}
+
+	if res.RetryAfter != time.Second {
+		t.Fatalf("expected retry after 1s, got %s", res.RetryAfter)
+	}
+
+	if !res.ResetAt.Equal(clock.Now().Add(time.Second)) {
+		t.Fatalf("expected reset one interval from now, got %v", res.ResetAt)
+	}
+}
+
+func TestGCRA_RecoversOneIntervalAtATime(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	st := store.NewMemoryStoreWithCleanupInterval(time.Minute)
+	limiter := NewGCRALimiter(st, clock, LimitConfig{Limit: 2, Window: 2 * time.Second}, nil)
+
+	limiter.Allow("test-key")
+	limiter.Allow("test-key")
+
+	clock.Advance(999 * time.Millisecond)
+	if res := limiter.Allow("test-key"); res.Allowed {
+		t.Fatalf("expected request before the emission interval to be denied")
+	}
+
+	clock.Advance(time.Millisecond)
+	if res := limiter.Allow("test-key"); !res.Allowed {
+		t.Fatalf("expected request after the emission interval to be allowed")
+	}
+
+	if res := limiter.Allow("test-key"); res.Allowed {
+		t.Fatalf("expected only one interval worth of capacity")
+	}
+}
+
+func TestGCRA_IdleKeyResetsToFullBurst(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	st := store.NewMemoryStoreWithCleanupInterval(time.Minute)
+	limiter := NewGCRALimiter(st, clock, LimitConfig{Limit: 2, Window: time.Second}, nil)
+
+	limiter.Allow("test-key")
+	limiter.Allow("test-key")
+
+	clock.Advance(time.Hour)
+
+	res := limiter.Allow("test-key")
+	if !res.Allowed || res.Remaining != 1 {
+		t.Fatalf("expected full burst after idling, got %+v", res)
+	}
+
+	if !res.ResetAt.Equal(clock.Now().Add(500 * time.Millisecond)) {
+		t.Fatalf("expected reset when the bucket is full again, got %v", res.ResetAt)
+	}
+}
+
+func TestGCRA_IsPerKey(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	st := store.NewMemoryStoreWithCleanupInterval(time.Minute)
+	limiter := NewGCRALimiter(st, clock, LimitConfig{Limit: 1, Window: time.Minute}, nil)
+
+	res1 := limiter.Allow("test-one")
+	res2 := limiter.Allow("test-two")
+
+	if !res1.Allowed || !res2.Allowed {
+		t.Fatalf("keys should have independent state")
+	}
+}
+
+func TestGCRA_AllowNAdvancesByCost(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	st := store.NewMemoryStoreWithCleanupInterval(time.Minute)
+	limiter := NewGCRALimiter(st, clock, LimitConfig{Limit: 4, Window: 4 * time.Second}, nil)
+
+	res, _ := limiter.AllowN(context.Background(), "test-key", 3)
+	if !res.Allowed || res.Remaining != 1 {
+		t.Fatalf("expected cost of 3 to leave 1 remaining, got %+v", res)
+	}
+
+	res, _ = limiter.AllowN(context.Background(), "test-key", 2)
+	if res.Allowed {
+		t.Fatalf("expected request exceeding the burst to be denied")
+	}
+
+	if res.RetryAfter != time.Second {
+		t.Fatalf("expected retry after 1s, got %s", res.RetryAfter)
+	}
+}
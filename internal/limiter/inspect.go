@@ -0,0 +1,117 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bellettati/go-rate-limited-api/internal/store"
+)
+
+var ErrNotInspectable = errors.New("limiter does not support inspection")
+
+const topKeysScanLimit = 1000
+
+type KeyState struct {
+	Key    string
+	Limit  int
+	Window time.Duration
+	// Used is the count of the window, the tokens missing from the bucket, or
+	// the units still ahead of now.
+	Used      float64
+	Remaining int
+	ResetAt   time.Time
+
+	// Tokens is set by token buckets, Timestamps by the in-process sliding
+	// window and TAT by GCRA and the leaky bucket.
+	Tokens     *float64
+	Timestamps []time.Time
+	TAT        *time.Time
+
+	// Windows is set by CompositeLimiter, longest first.
+	Windows []KeyState
+}
+
+type Inspector interface {
+	Inspect(ctx context.Context, apiKey string) (KeyState, error)
+	Reset(ctx context.Context, apiKey string) error
+	// owns tells apart the keys of limiters sharing a store; nil accepts all.
+	TopKeys(ctx context.Context, n int, owns func(apiKey string) bool) ([]KeyState, error)
+}
+
+func TopByUsage(states []KeyState, n int) []KeyState {
+	sort.Slice(states, func(i, j int) bool {
+		if ui, uj := Usage(states[i]), Usage(states[j]); ui != uj {
+			return ui > uj
+		}
+		return states[i].Key < states[j].Key
+	})
+
+	if len(states) > n {
+		states = states[:max(n, 0)]
+	}
+
+	return states
+}
+
+func Usage(s KeyState) float64 {
+	u := 0.0
+	if s.Limit > 0 {
+		u = s.Used / float64(s.Limit)
+	}
+
+	for _, w := range s.Windows {
+		u = max(u, Usage(w))
+	}
+
+	return u
+}
+
+func topStoredKeys(
+	ctx context.Context,
+	st store.Store,
+	prefix string,
+	n int,
+	owns func(apiKey string) bool,
+	apiKeyOf func(rest string) string,
+	inspect func(ctx context.Context, apiKey string) (KeyState, error),
+) ([]KeyState, error) {
+	keys, err := st.Keys(ctx, prefix, topKeysScanLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(keys))
+	var states []KeyState
+	for _, key := range keys {
+		apiKey := apiKeyOf(strings.TrimPrefix(key, prefix))
+		if seen[apiKey] || (owns != nil && !owns(apiKey)) {
+			continue
+		}
+		seen[apiKey] = true
+
+		state, err := inspect(ctx, apiKey)
+		if err != nil {
+			return nil, err
+		}
+		if state.Used > 0 {
+			states = append(states, state)
+		}
+	}
+
+	return TopByUsage(states, n), nil
+}
+
+func trimWindowStart(rest string) string {
+	if i := strings.LastIndexByte(rest, ':'); i >= 0 {
+		return rest[:i]
+	}
+
+	return rest
+}
+
+func sameKey(rest string) string {
+	return rest
+}
@@ -0,0 +1,107 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bellettati/go-rate-limited-api/internal/store"
+)
+
+func TestInspector_ReportsTopKeysAndResets(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cfg := LimitConfig{Limit: 5, Window: time.Minute}
+
+	limiters := map[string]interface {
+		Limiter
+		Inspector
+	}{
+		"fixed_window":           NewFixedWindowLimiter(store.NewMemoryStore(), clock, cfg, nil),
+		"sliding_window":         NewSlidingWindowLimiter(clock, cfg, nil),
+		"sliding_window_counter": NewSlidingWindowCounterLimiter(store.NewMemoryStore(), clock, cfg, nil),
+		"token_bucket":           NewTokenBucketLimiter(clock, cfg, nil),
+		"distributed_bucket":     NewDistributedTokenBucketLimiter(store.NewMemoryStore(), clock, cfg, nil),
+		"gcra":                   NewGCRALimiter(store.NewMemoryStore(), clock, cfg, nil),
+		"leaky_bucket":           NewLeakyBucketLimiter(store.NewMemoryStore(), clock, cfg, nil, QueueConfig{Depth: 5}),
+	}
+
+	for name, rl := range limiters {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			rl.AllowN(ctx, "busy-key", 3)
+			rl.AllowN(ctx, "quiet-key", 1)
+
+			state, err := rl.Inspect(ctx, "busy-key")
+			if err != nil {
+				t.Fatalf("unexpected inspect error: %v", err)
+			}
+			if state.Used != 3 || state.Limit != 5 || !state.ResetAt.After(clock.Now()) {
+				t.Fatalf("expected 3 of 5 used with a future reset, got %+v", state)
+			}
+
+			// Inspecting must not charge the key.
+			if again, _ := rl.Inspect(ctx, "busy-key"); again.Used != 3 {
+				t.Fatalf("expected inspect to leave the key alone, got %+v", again)
+			}
+
+			top, err := rl.TopKeys(ctx, 1, nil)
+			if err != nil {
+				t.Fatalf("unexpected top keys error: %v", err)
+			}
+			if len(top) != 1 || top[0].Key != "busy-key" {
+				t.Fatalf("expected busy-key on top, got %+v", top)
+			}
+
+			top, _ = rl.TopKeys(ctx, 10, func(apiKey string) bool { return apiKey != "busy-key" })
+			if len(top) != 1 || top[0].Key != "quiet-key" {
+				t.Fatalf("expected owns to filter keys, got %+v", top)
+			}
+
+			if err := rl.Reset(ctx, "busy-key"); err != nil {
+				t.Fatalf("unexpected reset error: %v", err)
+			}
+			if state, _ := rl.Inspect(ctx, "busy-key"); state.Used != 0 {
+				t.Fatalf("expected reset key to be unused, got %+v", state)
+			}
+		})
+	}
+}
+
+func TestComposite_InspectReportsEveryWindow(t *testing.T) {
+	clock := NewFakeClock(nextHour())
+	limiter := newTestComposite(clock, []LimitConfig{
+		{Limit: 10, Window: time.Second},
+		{Limit: 100, Window: time.Hour},
+	}, nil)
+	ctx := context.Background()
+
+	limiter.AllowN(ctx, "busy-key", 8)
+	limiter.AllowN(ctx, "quiet-key", 1)
+
+	state, err := limiter.Inspect(ctx, "busy-key")
+	if err != nil {
+		t.Fatalf("unexpected inspect error: %v", err)
+	}
+	if len(state.Windows) != 2 || state.Windows[0].Window != time.Hour {
+		t.Fatalf("expected both windows, longest first, got %+v", state.Windows)
+	}
+	if state.Window != time.Second || state.Remaining != 2 {
+		t.Fatalf("expected the per-second window to be most restrictive, got %+v", state)
+	}
+
+	top, err := limiter.TopKeys(ctx, 10, nil)
+	if err != nil {
+		t.Fatalf("unexpected top keys error: %v", err)
+	}
+	if len(top) != 2 || top[0].Key != "busy-key" || top[1].Key != "quiet-key" {
+		t.Fatalf("expected each key once, without window suffixes, got %+v", top)
+	}
+
+	if err := limiter.Reset(ctx, "busy-key"); err != nil {
+		t.Fatalf("unexpected reset error: %v", err)
+	}
+	if res := limiter.Allow("busy-key"); res.Remaining != 9 {
+		t.Fatalf("expected every window to be reset, got %+v", res)
+	}
+}
@@ -0,0 +1,191 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bellettati/go-rate-limited-api/internal/store"
+)
+
+type QueueConfig struct {
+	Depth   int
+	MaxWait time.Duration
+}
+
+type LeakyBucketLimiter struct {
+	st     store.Store
+	limits *Limits
+	clock  Clock
+	queue  QueueConfig
+}
+
+func NewLeakyBucketLimiter(st store.Store, clock Clock, defaultLimit LimitConfig, overrides map[string]LimitConfig, queue QueueConfig) *LeakyBucketLimiter {
+	if queue.Depth < 0 {
+		queue.Depth = 0
+	}
+
+	return &LeakyBucketLimiter{
+		st:     st,
+		limits: NewLimits(defaultLimit, overrides),
+		clock:  clock,
+		queue:  queue,
+	}
+}
+
+func (lb *LeakyBucketLimiter) configFor(apiKey string) LimitConfig {
+	return lb.limits.For(apiKey)
+}
+
+func (lb *LeakyBucketLimiter) SetLimits(defaultLimit LimitConfig, overrides map[string]LimitConfig) {
+	lb.limits.Set(defaultLimit, overrides)
+}
+
+func (lb *LeakyBucketLimiter) Allow(apiKey string) RateLimitResult {
+	res, err := lb.AllowN(context.Background(), apiKey, 1)
+	if err != nil {
+		return failOpen(res)
+	}
+
+	return res
+}
+
+func (lb *LeakyBucketLimiter) AllowN(ctx context.Context, apiKey string, n int) (RateLimitResult, error) {
+	res, _, err := lb.schedule(ctx, apiKey, normalizeCost(n), 0)
+	return res, err
+}
+
+func (lb *LeakyBucketLimiter) Reserve(ctx context.Context, apiKey string) (*Reservation, error) {
+	return lb.ReserveN(ctx, apiKey, 1)
+}
+
+func (lb *LeakyBucketLimiter) ReserveN(ctx context.Context, apiKey string, n int) (*Reservation, error) {
+	n = normalizeCost(n)
+
+	cfg := lb.configFor(apiKey)
+	interval := cfg.Window / time.Duration(cfg.Limit)
+
+	maxDelay := lb.queueCapacity(interval)
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < maxDelay {
+		maxDelay = max(time.Until(deadline), 0)
+	}
+
+	res, delay, err := lb.schedule(ctx, apiKey, n, maxDelay)
+	if err != nil {
+		return nil, err
+	}
+
+	cost := interval * time.Duration(n)
+	reserved := res.ResetAt
+
+	// Cancel only hands the slot back while it is still the last one queued, so
+	// requests behind it keep their delays. maxAhead makes the update a
+	// compare-and-set, with half an interval of slack for stores that round.
+	return newReservation(res, delay, func(ctx context.Context) error {
+		now := lb.clock.Now()
+		ahead := reserved.Sub(now) - cost
+		if ahead < 0 {
+			return nil
+		}
+
+		_, _, err := lb.st.UpdateTAT(ctx, leakyBucketKey(apiKey), now, -cost, ahead+interval/2)
+		return err
+	}), nil
+}
+
+func (lb *LeakyBucketLimiter) Wait(ctx context.Context, apiKey string) error {
+	return lb.WaitN(ctx, apiKey, 1)
+}
+
+func (lb *LeakyBucketLimiter) WaitN(ctx context.Context, apiKey string, n int) error {
+	return waitN(ctx, lb, lb.clock, apiKey, n)
+}
+
+func leakyBucketKey(apiKey string) string {
+	return "rl:leaky:" + apiKey
+}
+
+func (lb *LeakyBucketLimiter) schedule(ctx context.Context, apiKey string, n int, maxDelay time.Duration) (RateLimitResult, time.Duration, error) {
+	cfg := lb.configFor(apiKey)
+	now := lb.clock.Now()
+
+	interval := cfg.Window / time.Duration(cfg.Limit)
+	cost := interval * time.Duration(n)
+
+	allowed, tat, err := lb.st.UpdateTAT(ctx, leakyBucketKey(apiKey), now, cost, maxDelay+cost)
+	if err != nil {
+		return RateLimitResult{ResetAt: now.Add(cfg.Window), Limit: cfg.Limit, Window: cfg.Window}, 0, fmt.Errorf("leaky bucket update: %w", err)
+	}
+
+	delay := tat.Sub(now) - cost
+	if !allowed {
+		retryAfter := delay - maxDelay
+		return RateLimitResult{
+			Allowed:    false,
+			Remaining:  0,
+			ResetAt:    now.Add(retryAfter),
+			Limit:      cfg.Limit,
+			Window:     cfg.Window,
+			RetryAfter: retryAfter,
+		}, 0, nil
+	}
+
+	remaining := 0
+	if slack := lb.queueCapacity(interval) - (tat.Sub(now) - interval); slack > 0 {
+		remaining = int(slack / interval)
+	}
+
+	return RateLimitResult{
+		Allowed:   true,
+		Remaining: remaining,
+		ResetAt:   tat,
+		Limit:     cfg.Limit,
+		Window:    cfg.Window,
+	}, delay, nil
+}
+
+func (lb *LeakyBucketLimiter) queueCapacity(interval time.Duration) time.Duration {
+	capacity := interval * time.Duration(lb.queue.Depth)
+	if lb.queue.MaxWait > 0 && lb.queue.MaxWait < capacity {
+		capacity = lb.queue.MaxWait
+	}
+
+	return capacity
+}
+
+func (lb *LeakyBucketLimiter) Inspect(ctx context.Context, apiKey string) (KeyState, error) {
+	cfg := lb.configFor(apiKey)
+	now := lb.clock.Now()
+	interval := cfg.Window / time.Duration(cfg.Limit)
+	capacity := lb.queueCapacity(interval)
+
+	tat, err := lb.st.GetTAT(ctx, leakyBucketKey(apiKey))
+	if err != nil {
+		return KeyState{}, fmt.Errorf("leaky bucket get: %w", err)
+	}
+
+	ahead := max(tat.Sub(now), 0)
+	state := KeyState{
+		Key:     apiKey,
+		Limit:   cfg.Limit,
+		Window:  cfg.Window,
+		Used:    float64(ahead) / float64(interval),
+		ResetAt: now.Add(ahead),
+	}
+	if ahead <= capacity {
+		state.Remaining = int((capacity-ahead)/interval) + 1
+	}
+	if !tat.IsZero() {
+		state.TAT = &tat
+	}
+
+	return state, nil
+}
+
+func (lb *LeakyBucketLimiter) Reset(ctx context.Context, apiKey string) error {
+	return lb.st.Delete(ctx, leakyBucketKey(apiKey))
+}
+
+func (lb *LeakyBucketLimiter) TopKeys(ctx context.Context, n int, owns func(apiKey string) bool) ([]KeyState, error) {
+	return topStoredKeys(ctx, lb.st, "rl:leaky:", n, owns, sameKey, lb.Inspect)
+}
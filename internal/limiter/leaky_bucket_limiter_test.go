@@ -0,0 +1,167 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bellettati/go-rate-limited-api/internal/store"
+)
+
+func TestLeakyBucket_AllowOnlyAdmitsAtDrainRate(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	st := store.NewMemoryStoreWithCleanupInterval(time.Minute)
+	limiter := NewLeakyBucketLimiter(st, clock, LimitConfig{Limit: 2, Window: time.Second}, nil, QueueConfig{Depth: 4})
+
+	if res := limiter.Allow("test-key"); !res.Allowed {
+		t.Fatalf("expected first request to be allowed")
+	}
+
+	res := limiter.Allow("test-key")
+	if res.Allowed {
+		t.Fatalf("expected second immediate request to be denied")
+	}
+
+	if res.RetryAfter != 500*time.Millisecond {
+		t.Fatalf("expected retry after 500ms, got %s", res.RetryAfter)
+	}
+
+	clock.Advance(500 * time.Millisecond)
+	if res := limiter.Allow("test-key"); !res.Allowed {
+		t.Fatalf("expected request after one drain interval to be allowed")
+	}
+}
+
+func TestLeakyBucket_ReserveQueuesUpToDepth(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	st := store.NewMemoryStoreWithCleanupInterval(time.Minute)
+	limiter := NewLeakyBucketLimiter(st, clock, LimitConfig{Limit: 10, Window: time.Second}, nil, QueueConfig{Depth: 3})
+	ctx := context.Background()
+
+	for i := 0; i < 4; i++ {
+		r, err := limiter.Reserve(ctx, "test-key")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !r.OK() {
+			t.Fatalf("expected request %d to be queued", i+1)
+		}
+
+		want := time.Duration(i) * 100 * time.Millisecond
+		if r.Delay() != want {
+			t.Fatalf("expected request %d to wait %s, got %s", i+1, want, r.Delay())
+		}
+
+		if r.Result().Remaining != 3-i {
+			t.Fatalf("expected %d queue slots left, got %d", 3-i, r.Result().Remaining)
+		}
+	}
+
+	r, err := limiter.Reserve(ctx, "test-key")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if r.OK() {
+		t.Fatalf("expected request to be rejected when the queue is full")
+	}
+
+	if r.Delay() != 100*time.Millisecond {
+		t.Fatalf("expected retry after one drain interval, got %s", r.Delay())
+	}
+}
+
+func TestLeakyBucket_MaxWaitCapsQueue(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	st := store.NewMemoryStoreWithCleanupInterval(time.Minute)
+	limiter := NewLeakyBucketLimiter(st, clock, LimitConfig{Limit: 10, Window: time.Second}, nil, QueueConfig{Depth: 100, MaxWait: 250 * time.Millisecond})
+	ctx := context.Background()
+
+	queued := 0
+	for i := 0; i < 10; i++ {
+		r, err := limiter.Reserve(ctx, "test-key")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if r.OK() {
+			queued++
+		}
+	}
+
+	if queued != 3 {
+		t.Fatalf("expected 3 requests within 250ms of waiting, got %d", queued)
+	}
+}
+
+func TestLeakyBucket_RejectsWaitBeyondDeadline(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	st := store.NewMemoryStoreWithCleanupInterval(time.Minute)
+	limiter := NewLeakyBucketLimiter(st, clock, LimitConfig{Limit: 1, Window: time.Second}, nil, QueueConfig{Depth: 10})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	if r, _ := limiter.Reserve(ctx, "test-key"); !r.OK() {
+		t.Fatalf("expected first request to run immediately")
+	}
+
+	r, err := limiter.Reserve(ctx, "test-key")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if r.OK() {
+		t.Fatalf("expected request that would outlive its deadline to be rejected")
+	}
+}
+
+func TestLeakyBucket_CancelFreesQueueSlot(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	st := store.NewMemoryStoreWithCleanupInterval(time.Minute)
+	limiter := NewLeakyBucketLimiter(st, clock, LimitConfig{Limit: 10, Window: time.Second}, nil, QueueConfig{Depth: 1})
+	ctx := context.Background()
+
+	limiter.Reserve(ctx, "test-key")
+	queued, _ := limiter.Reserve(ctx, "test-key")
+	if !queued.OK() {
+		t.Fatalf("expected second request to be queued")
+	}
+
+	if r, _ := limiter.Reserve(ctx, "test-key"); r.OK() {
+		t.Fatalf("expected queue to be full")
+	}
+
+	if err := queued.Cancel(ctx); err != nil {
+		t.Fatalf("unexpected cancel error: %v", err)
+	}
+
+	r, _ := limiter.Reserve(ctx, "test-key")
+	if !r.OK() || r.Delay() != 100*time.Millisecond {
+		t.Fatalf("expected cancelled slot to be reused, got ok=%v delay=%s", r.OK(), r.Delay())
+	}
+}
+
+func TestLeakyBucket_CancelMidQueueKeepsTheDrainRate(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	st := store.NewMemoryStoreWithCleanupInterval(time.Minute)
+	limiter := NewLeakyBucketLimiter(st, clock, LimitConfig{Limit: 1, Window: time.Second}, nil, QueueConfig{Depth: 5})
+	ctx := context.Background()
+
+	limiter.Reserve(ctx, "test-key")
+	middle, _ := limiter.Reserve(ctx, "test-key")
+	last, _ := limiter.Reserve(ctx, "test-key")
+	if middle.Delay() != time.Second || last.Delay() != 2*time.Second {
+		t.Fatalf("expected delays of 1s and 2s, got %s and %s", middle.Delay(), last.Delay())
+	}
+
+	if err := middle.Cancel(ctx); err != nil {
+		t.Fatalf("unexpected cancel error: %v", err)
+	}
+
+	// The request queued behind the cancelled one still starts at 2s, so the
+	// next one must not be scheduled alongside it.
+	if r, _ := limiter.Reserve(ctx, "test-key"); !r.OK() || r.Delay() != 3*time.Second {
+		t.Fatalf("expected the next request to queue after the last one at 3s, got ok=%v delay=%s", r.OK(), r.Delay())
+	}
+}
@@ -1,19 +1,73 @@
 package limiter
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 type Limiter interface {
+	// A denied request is not charged. On error the result only carries Limit
+	// and ResetAt.
+	AllowN(ctx context.Context, apiKey string, n int) (RateLimitResult, error)
+}
+
+type LegacyLimiter interface {
 	Allow(apiKey string) RateLimitResult
 }
 
+type legacyAdapter struct {
+	l LegacyLimiter
+}
+
+// FromLegacy stops at the first denial, so a denied weighted request may be
+// partially charged.
+func FromLegacy(l LegacyLimiter) Limiter {
+	return legacyAdapter{l: l}
+}
+
+func (a legacyAdapter) AllowN(ctx context.Context, apiKey string, n int) (RateLimitResult, error) {
+	if err := ctx.Err(); err != nil {
+		return RateLimitResult{}, err
+	}
+
+	var res RateLimitResult
+	for i := 0; i < normalizeCost(n); i++ {
+		res = a.l.Allow(apiKey)
+		if !res.Allowed {
+			break
+		}
+	}
+
+	return res, nil
+}
+
 type LimitConfig struct {
 	Limit  int
 	Window time.Duration
 }
 
 type RateLimitResult struct {
-	Allowed   bool
-	Remaining int
-	ResetAt   time.Time
-	Limit     int
+	Allowed    bool
+	Remaining  int
+	ResetAt    time.Time
+	Limit      int
+	Window     time.Duration
+	RetryAfter time.Duration
+	Fallback   bool
+	Windows    []RateLimitResult
+}
+
+func normalizeCost(n int) int {
+	if n < 1 {
+		return 1
+	}
+
+	return n
+}
+
+func failOpen(res RateLimitResult) RateLimitResult {
+	res.Allowed = true
+	res.Remaining = res.Limit
+	res.RetryAfter = 0
+	return res
 }
@@ -0,0 +1,43 @@
+package limiter
+
+import "sync/atomic"
+
+type limitSet struct {
+	defaultLimit LimitConfig
+	overrides    map[string]LimitConfig
+}
+
+// Readers always see one consistent pair of default and overrides.
+type Limits struct {
+	current atomic.Pointer[limitSet]
+}
+
+func NewLimits(defaultLimit LimitConfig, overrides map[string]LimitConfig) *Limits {
+	l := &Limits{}
+	l.Set(defaultLimit, overrides)
+	return l
+}
+
+func (l *Limits) For(apiKey string) LimitConfig {
+	set := l.current.Load()
+	if cfg, ok := set.overrides[apiKey]; ok {
+		return cfg
+	}
+
+	return set.defaultLimit
+}
+
+// The overrides map must not be modified after Set.
+func (l *Limits) Set(defaultLimit LimitConfig, overrides map[string]LimitConfig) {
+	if overrides == nil {
+		overrides = make(map[string]LimitConfig)
+	}
+
+	l.current.Store(&limitSet{defaultLimit: defaultLimit, overrides: overrides})
+}
+
+// Per-key state is kept and judged against the new limits from the next
+// request on.
+type Reconfigurable interface {
+	SetLimits(defaultLimit LimitConfig, overrides map[string]LimitConfig)
+}
@@ -0,0 +1,117 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrWaitExceedsDeadline = errors.New("limiter: wait would exceed context deadline")
+
+type Reserver interface {
+	ReserveN(ctx context.Context, apiKey string, n int) (*Reservation, error)
+}
+
+type Reservation struct {
+	result RateLimitResult
+	delay  time.Duration
+	refund func(ctx context.Context) error
+
+	mu       sync.Mutex
+	canceled bool
+}
+
+func newReservation(res RateLimitResult, delay time.Duration, refund func(ctx context.Context) error) *Reservation {
+	if !res.Allowed {
+		refund = nil
+		delay = res.RetryAfter
+	}
+
+	return &Reservation{result: res, delay: delay, refund: refund}
+}
+
+func (r *Reservation) OK() bool {
+	return r.result.Allowed
+}
+
+func (r *Reservation) Delay() time.Duration {
+	return r.delay
+}
+
+func (r *Reservation) Result() RateLimitResult {
+	return r.result
+}
+
+func (r *Reservation) Cancel(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.canceled || r.refund == nil {
+		return nil
+	}
+
+	r.canceled = true
+	return r.refund(ctx)
+}
+
+func Reserve(ctx context.Context, l Limiter, apiKey string, n int) (*Reservation, error) {
+	if r, ok := l.(Reserver); ok {
+		return r.ReserveN(ctx, apiKey, n)
+	}
+
+	res, err := l.AllowN(ctx, apiKey, n)
+	if err != nil {
+		return nil, err
+	}
+
+	return newReservation(res, 0, nil), nil
+}
+
+func waitN(ctx context.Context, r Reserver, clock Clock, apiKey string, n int) error {
+	for {
+		res, err := r.ReserveN(ctx, apiKey, n)
+		if err != nil {
+			return err
+		}
+
+		if res.OK() {
+			if res.Delay() <= 0 {
+				return nil
+			}
+
+			if err := sleep(ctx, clock, res.Delay()); err != nil {
+				_ = res.Cancel(context.WithoutCancel(ctx))
+				return err
+			}
+
+			return nil
+		}
+
+		if limit := res.Result().Limit; n > limit {
+			return fmt.Errorf("limiter: cost %d exceeds limit %d", n, limit)
+		}
+
+		if err := sleep(ctx, clock, res.Delay()); err != nil {
+			return err
+		}
+	}
+}
+
+func sleep(ctx context.Context, clock Clock, d time.Duration) error {
+	if d <= 0 {
+		d = time.Millisecond
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+		return ErrWaitExceedsDeadline
+	}
+
+	select {
+	case <-clock.After(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
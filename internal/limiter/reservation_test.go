@@ -0,0 +1,175 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bellettati/go-rate-limited-api/internal/store"
+)
+
+func TestReservation_CancelReturnsUnits(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cfg := LimitConfig{Limit: 2, Window: time.Minute}
+
+	limiters := map[string]Reserver{
+		"fixed_window":           NewFixedWindowLimiter(store.NewMemoryStore(), clock, cfg, nil),
+		"sliding_window":         NewSlidingWindowLimiter(clock, cfg, nil),
+		"sliding_window_counter": NewSlidingWindowCounterLimiter(store.NewMemoryStore(), clock, cfg, nil),
+		"token_bucket":           NewTokenBucketLimiter(clock, cfg, nil),
+		"distributed_bucket":     NewDistributedTokenBucketLimiter(store.NewMemoryStore(), clock, cfg, nil),
+		"gcra":                   NewGCRALimiter(store.NewMemoryStore(), clock, cfg, nil),
+	}
+
+	for name, rl := range limiters {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			r, err := rl.ReserveN(ctx, "test-key", 2)
+			if err != nil || !r.OK() {
+				t.Fatalf("expected reservation to be granted, got %+v err=%v", r, err)
+			}
+
+			if denied, _ := rl.ReserveN(ctx, "test-key", 1); denied.OK() {
+				t.Fatalf("expected limit to be exhausted")
+			}
+
+			if err := r.Cancel(ctx); err != nil {
+				t.Fatalf("unexpected cancel error: %v", err)
+			}
+
+			again, _ := rl.ReserveN(ctx, "test-key", 2)
+			if !again.OK() {
+				t.Fatalf("expected cancelled units to be available again, got %+v", again.Result())
+			}
+		})
+	}
+}
+
+func TestReservation_CancelRefundsTheReservedLimits(t *testing.T) {
+	// Half past an hour, so the minute and hour windows start apart.
+	clock := NewFakeClock(time.Now().Truncate(time.Hour).Add(90 * time.Minute))
+	reserved := LimitConfig{Limit: 1, Window: time.Minute}
+	reloaded := LimitConfig{Limit: 120, Window: time.Hour}
+
+	type reconfigurable interface {
+		Limiter
+		Reserver
+		SetLimits(defaultLimit LimitConfig, overrides map[string]LimitConfig)
+	}
+
+	limiters := map[string]reconfigurable{
+		"fixed_window":           NewFixedWindowLimiter(store.NewMemoryStore(), clock, reserved, nil),
+		"sliding_window_counter": NewSlidingWindowCounterLimiter(store.NewMemoryStore(), clock, reserved, nil),
+		"gcra":                   NewGCRALimiter(store.NewMemoryStore(), clock, reserved, nil),
+	}
+
+	for name, rl := range limiters {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			r, err := rl.ReserveN(ctx, "test-key", 1)
+			if err != nil || !r.OK() {
+				t.Fatalf("expected reservation to be granted, got %+v err=%v", r, err)
+			}
+
+			rl.SetLimits(reloaded, nil)
+			if err := r.Cancel(ctx); err != nil {
+				t.Fatalf("unexpected cancel error: %v", err)
+			}
+			rl.SetLimits(reserved, nil)
+
+			if res, _ := rl.AllowN(ctx, "test-key", 1); !res.Allowed {
+				t.Fatalf("expected the unit to go back to the limits it was reserved under, got %+v", res)
+			}
+		})
+	}
+}
+
+func TestReservation_CancelAfterResetKeepsNewerSlots(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	rl := NewSlidingWindowLimiter(clock, LimitConfig{Limit: 5, Window: time.Minute}, nil)
+	ctx := context.Background()
+
+	r, _ := rl.Reserve(ctx, "test-key")
+	rl.Reset(ctx, "test-key")
+	rl.Reserve(ctx, "test-key")
+
+	r.Cancel(ctx)
+
+	if state, _ := rl.Inspect(ctx, "test-key"); state.Used != 1 {
+		t.Fatalf("expected cancel to leave the newer request's slot alone, got %+v", state)
+	}
+}
+
+func TestReservation_DeniedReportsDelay(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	rl := NewGCRALimiter(store.NewMemoryStore(), clock, LimitConfig{Limit: 1, Window: time.Second}, nil)
+
+	rl.Reserve(context.Background(), "test-key")
+
+	r, err := rl.Reserve(context.Background(), "test-key")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if r.OK() || r.Delay() != time.Second {
+		t.Fatalf("expected denied reservation with 1s delay, got ok=%v delay=%s", r.OK(), r.Delay())
+	}
+
+	if err := r.Cancel(context.Background()); err != nil {
+		t.Fatalf("expected cancelling a denied reservation to be a no-op, got %v", err)
+	}
+}
+
+func TestWait_BlocksUntilPermitted(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	rl := NewTokenBucketLimiter(clock, LimitConfig{Limit: 1, Window: time.Second}, nil)
+
+	rl.Allow("test-key")
+
+	done := make(chan error, 1)
+	go func() {
+		done <- rl.Wait(context.Background(), "test-key")
+	}()
+
+	for clock.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	select {
+	case err := <-done:
+		t.Fatalf("expected Wait to block, returned %v", err)
+	default:
+	}
+
+	clock.Advance(time.Second)
+
+	if err := <-done; err != nil {
+		t.Fatalf("expected Wait to succeed after refill, got %v", err)
+	}
+}
+
+func TestWait_FailsFastPastDeadline(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	rl := NewFixedWindowLimiter(store.NewMemoryStore(), clock, LimitConfig{Limit: 1, Window: time.Hour}, nil)
+
+	rl.Allow("test-key")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := rl.Wait(ctx, "test-key"); !errors.Is(err, ErrWaitExceedsDeadline) {
+		t.Fatalf("expected ErrWaitExceedsDeadline, got %v", err)
+	}
+}
+
+func TestWait_RejectsCostAboveLimit(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	rl := NewTokenBucketLimiter(clock, LimitConfig{Limit: 2, Window: time.Second}, nil)
+
+	if err := rl.WaitN(context.Background(), "test-key", 3); err == nil {
+		t.Fatalf("expected error for a cost that can never be satisfied")
+	}
+}
@@ -0,0 +1,197 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/bellettati/go-rate-limited-api/internal/store"
+)
+
+type SlidingWindowCounterLimiter struct {
+	st     store.Store
+	limits *Limits
+	clock  Clock
+}
+
+func NewSlidingWindowCounterLimiter(st store.Store, clock Clock, defaultLimit LimitConfig, overrides map[string]LimitConfig) *SlidingWindowCounterLimiter {
+	return &SlidingWindowCounterLimiter{
+		st:     st,
+		limits: NewLimits(defaultLimit, overrides),
+		clock:  clock,
+	}
+}
+
+func (sc *SlidingWindowCounterLimiter) configFor(apiKey string) LimitConfig {
+	return sc.limits.For(apiKey)
+}
+
+func (sc *SlidingWindowCounterLimiter) SetLimits(defaultLimit LimitConfig, overrides map[string]LimitConfig) {
+	sc.limits.Set(defaultLimit, overrides)
+}
+
+func (sc *SlidingWindowCounterLimiter) Allow(apiKey string) RateLimitResult {
+	res, err := sc.AllowN(context.Background(), apiKey, 1)
+	if err != nil {
+		return failOpen(res)
+	}
+
+	return res
+}
+
+func (sc *SlidingWindowCounterLimiter) AllowN(ctx context.Context, apiKey string, n int) (RateLimitResult, error) {
+	return sc.allowN(ctx, apiKey, normalizeCost(n), sc.clock.Now())
+}
+
+func (sc *SlidingWindowCounterLimiter) Reserve(ctx context.Context, apiKey string) (*Reservation, error) {
+	return sc.ReserveN(ctx, apiKey, 1)
+}
+
+func (sc *SlidingWindowCounterLimiter) ReserveN(ctx context.Context, apiKey string, n int) (*Reservation, error) {
+	n = normalizeCost(n)
+	now := sc.clock.Now()
+
+	res, err := sc.allowN(ctx, apiKey, n, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return newReservation(res, 0, func(ctx context.Context) error {
+		window := res.Window
+		windowStart, windowEnd := windowBounds(now, window)
+
+		// Once the reserved window has aged out of the previous-window
+		// weighting there is nothing left to give back.
+		expiresAt := windowEnd.Add(window)
+		if !sc.clock.Now().Before(expiresAt) {
+			return nil
+		}
+
+		_, _, err := sc.st.IncrByWithTTL(ctx, slidingWindowKey(apiKey, windowStart), -int64(n), expiresAt.Sub(sc.clock.Now()))
+		return err
+	}), nil
+}
+
+func (sc *SlidingWindowCounterLimiter) Wait(ctx context.Context, apiKey string) error {
+	return sc.WaitN(ctx, apiKey, 1)
+}
+
+func (sc *SlidingWindowCounterLimiter) WaitN(ctx context.Context, apiKey string, n int) error {
+	return waitN(ctx, sc, sc.clock, apiKey, n)
+}
+
+func slidingWindowKey(apiKey string, windowStart time.Time) string {
+	return "rl:sliding:" + apiKey + ":" + formatUnixNano(windowStart)
+}
+
+func (sc *SlidingWindowCounterLimiter) allowN(ctx context.Context, apiKey string, n int, now time.Time) (RateLimitResult, error) {
+	cfg := sc.configFor(apiKey)
+
+	currStart, currEnd := windowBounds(now, cfg.Window)
+	prevStart := currStart.Add(-cfg.Window)
+	prevWeight := 1 - float64(now.Sub(currStart))/float64(cfg.Window)
+
+	currKey := slidingWindowKey(apiKey, currStart)
+	prevKey := slidingWindowKey(apiKey, prevStart)
+
+	// The current window's counter must outlive its own window because it
+	// becomes the weighted "previous" window for the next one.
+	ttl := currEnd.Add(cfg.Window).Sub(now)
+
+	allowed, curr, prev, err := sc.st.SlidingWindowIncr(ctx, currKey, prevKey, int64(n), int64(cfg.Limit), prevWeight, ttl)
+	if err != nil {
+		return RateLimitResult{ResetAt: currEnd, Limit: cfg.Limit, Window: cfg.Window}, fmt.Errorf("sliding window incr: %w", err)
+	}
+
+	estimate := float64(prev)*prevWeight + float64(curr)
+	remaining := cfg.Limit - int(math.Ceil(estimate))
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	resetAt := currEnd
+	var retryAfter time.Duration
+	if !allowed {
+		resetAt = slidingWindowNextSlot(cfg, n, currStart, curr, prev)
+		retryAfter = resetAt.Sub(now)
+	}
+
+	return RateLimitResult{
+		Allowed:    allowed,
+		Remaining:  remaining,
+		ResetAt:    resetAt,
+		Limit:      cfg.Limit,
+		Window:     cfg.Window,
+		RetryAfter: retryAfter,
+	}, nil
+}
+
+func slidingWindowNextSlot(cfg LimitConfig, n int, currStart time.Time, curr, prev int64) time.Time {
+	free := float64(cfg.Limit - n)
+	if free < 0 {
+		free = 0
+	}
+
+	if float64(curr) <= free {
+		if prev == 0 {
+			return currStart
+		}
+		frac := 1 - (free-float64(curr))/float64(prev)
+		return currStart.Add(time.Duration(frac * float64(cfg.Window)))
+	}
+
+	// The current window alone is exhausted: wait until it becomes the
+	// previous window and its weight has decayed enough.
+	frac := 1 - free/float64(curr)
+	return currStart.Add(cfg.Window).Add(time.Duration(frac * float64(cfg.Window)))
+}
+
+func (sc *SlidingWindowCounterLimiter) Inspect(ctx context.Context, apiKey string) (KeyState, error) {
+	cfg := sc.configFor(apiKey)
+	now := sc.clock.Now()
+
+	currStart, currEnd := windowBounds(now, cfg.Window)
+	prevWeight := 1 - float64(now.Sub(currStart))/float64(cfg.Window)
+
+	curr, _, err := sc.st.Get(ctx, slidingWindowKey(apiKey, currStart))
+	if err != nil {
+		return KeyState{}, fmt.Errorf("sliding window get: %w", err)
+	}
+	prev, _, err := sc.st.Get(ctx, slidingWindowKey(apiKey, currStart.Add(-cfg.Window)))
+	if err != nil {
+		return KeyState{}, fmt.Errorf("sliding window get: %w", err)
+	}
+
+	// The estimate reaches zero once the current window has become the
+	// previous one and its weight has decayed.
+	resetAt := now
+	switch {
+	case curr > 0:
+		resetAt = currEnd.Add(cfg.Window)
+	case prev > 0:
+		resetAt = currEnd
+	}
+
+	estimate := float64(prev)*prevWeight + float64(curr)
+
+	return KeyState{
+		Key:       apiKey,
+		Limit:     cfg.Limit,
+		Window:    cfg.Window,
+		Used:      estimate,
+		Remaining: max(cfg.Limit-int(math.Ceil(estimate)), 0),
+		ResetAt:   resetAt,
+	}, nil
+}
+
+func (sc *SlidingWindowCounterLimiter) Reset(ctx context.Context, apiKey string) error {
+	window := sc.configFor(apiKey).Window
+	currStart, _ := windowBounds(sc.clock.Now(), window)
+
+	return sc.st.Delete(ctx, slidingWindowKey(apiKey, currStart), slidingWindowKey(apiKey, currStart.Add(-window)))
+}
+
+func (sc *SlidingWindowCounterLimiter) TopKeys(ctx context.Context, n int, owns func(apiKey string) bool) ([]KeyState, error) {
+	return topStoredKeys(ctx, sc.st, "rl:sliding:", n, owns, trimWindowStart, sc.Inspect)
+}
@@ -0,0 +1,91 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bellettati/go-rate-limited-api/internal/store"
+)
+
+func windowAligned(window time.Duration) time.Time {
+	return time.Now().Truncate(window)
+}
+
+func TestSlidingWindowCounter_AllowWithinLimit(t *testing.T) {
+	clock := NewFakeClock(windowAligned(time.Minute))
+	st := store.NewMemoryStoreWithCleanupInterval(time.Minute)
+	rl := NewSlidingWindowCounterLimiter(st, clock, LimitConfig{Limit: 3, Window: time.Minute}, nil)
+
+	for i := 0; i < 3; i++ {
+		res := rl.Allow("test-key")
+		if !res.Allowed {
+			t.Fatalf("expected request %d to be allowed", i+1)
+		}
+
+		if res.Remaining != 3-(i+1) {
+			t.Fatalf("expected remaining %d, got %d", 3-(i+1), res.Remaining)
+		}
+	}
+
+	if res := rl.Allow("test-key"); res.Allowed {
+		t.Fatalf("expected request over limit to be rejected")
+	}
+}
+
+func TestSlidingWindowCounter_WeightsPreviousWindow(t *testing.T) {
+	clock := NewFakeClock(windowAligned(time.Minute))
+	st := store.NewMemoryStoreWithCleanupInterval(time.Minute)
+	rl := NewSlidingWindowCounterLimiter(st, clock, LimitConfig{Limit: 4, Window: time.Minute}, nil)
+
+	for i := 0; i < 4; i++ {
+		rl.Allow("test-key")
+	}
+
+	// A quarter into the next window, 3 of the previous 4 requests still count.
+	clock.Advance(time.Minute + 15*time.Second)
+
+	if res := rl.Allow("test-key"); !res.Allowed {
+		t.Fatalf("expected one request to fit under the weighted estimate")
+	}
+
+	res := rl.Allow("test-key")
+	if res.Allowed {
+		t.Fatalf("expected weighted previous window to block the request")
+	}
+
+	if !res.ResetAt.After(clock.Now()) {
+		t.Fatalf("expected reset in the future, got %v (now %v)", res.ResetAt, clock.Now())
+	}
+}
+
+func TestSlidingWindowCounter_AllowedAfterTwoWindows(t *testing.T) {
+	clock := NewFakeClock(windowAligned(time.Minute))
+	st := store.NewMemoryStoreWithCleanupInterval(time.Minute)
+	rl := NewSlidingWindowCounterLimiter(st, clock, LimitConfig{Limit: 2, Window: time.Minute}, nil)
+
+	rl.Allow("test-key")
+	rl.Allow("test-key")
+
+	clock.Advance(2 * time.Minute)
+
+	res := rl.Allow("test-key")
+	if !res.Allowed || res.Remaining != 1 {
+		t.Fatalf("expected fresh budget after two windows, got %+v", res)
+	}
+}
+
+func TestSlidingWindowCounter_SharedStoreAcrossInstances(t *testing.T) {
+	clock := NewFakeClock(windowAligned(time.Minute))
+	st := store.NewMemoryStoreWithCleanupInterval(time.Minute)
+	cfg := LimitConfig{Limit: 2, Window: time.Minute}
+
+	a := NewSlidingWindowCounterLimiter(st, clock, cfg, nil)
+	b := NewSlidingWindowCounterLimiter(st, clock, cfg, nil)
+
+	a.Allow("test-key")
+	b.Allow("test-key")
+
+	if res := a.Allow("test-key"); res.Allowed {
+		t.Fatalf("expected limiters sharing a store to share the limit")
+	}
+}
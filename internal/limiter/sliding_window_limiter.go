@@ -1,48 +1,66 @@
 package limiter
 
 import (
+	"context"
 	"sync"
 	"time"
 )
 
 const slidingWindowCapMax = 256
 
+type slidingWindowEntry struct {
+	at time.Time
+	id uint64
+}
+
 type slidingWindowState struct {
-	timestamps  []time.Time
+	entries  []slidingWindowEntry
 	lastSeen time.Time
 }
 
 type SlidingWindowLimiter struct {
 	mu           sync.Mutex
 	clients      map[string]*slidingWindowState
-	defaultLimit LimitConfig
-	overrides    map[string]LimitConfig
+	lastID       uint64
+	limits       *Limits
 	clock Clock
+	cleanupLoop *cleanupLoop
 }
 
 func NewSlidingWindowLimiter(clock Clock, defaultLimit LimitConfig, overrides map[string]LimitConfig) *SlidingWindowLimiter {
-	if overrides == nil {
-		overrides = make(map[string]LimitConfig)
-	}
+	return NewSlidingWindowLimiterWithCleanupInterval(clock, defaultLimit, overrides, defaultCleanupInterval)
+}
 
+func NewSlidingWindowLimiterWithCleanupInterval(clock Clock, defaultLimit LimitConfig, overrides map[string]LimitConfig, interval time.Duration) *SlidingWindowLimiter {
 	sw := &SlidingWindowLimiter{
 		clients:      make(map[string]*slidingWindowState),
-		defaultLimit: defaultLimit,
-		overrides:    overrides,
+		limits:       NewLimits(defaultLimit, overrides),
 		clock: clock,
 	}
 
-	go sw.startCleanup()
+	sw.cleanupLoop = startCleanup(clock, interval, sw.cleanup)
 
 	return sw 
 }
 
+func (sw *SlidingWindowLimiter) Close() error {
+	sw.cleanupLoop.stop()
+	return nil
+}
+
 func (sw *SlidingWindowLimiter) configFor(apiKey string) LimitConfig {
-	if cfg, ok := sw.overrides[apiKey]; ok {
-		return cfg
-	}
+	return sw.limits.For(apiKey)
+}
+
+func (sw *SlidingWindowLimiter) SetLimits(defaultLimit LimitConfig, overrides map[string]LimitConfig) {
+	sw.limits.Set(defaultLimit, overrides)
+}
 
-	return sw.defaultLimit
+func (sw *SlidingWindowLimiter) TrackedKeys() int {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	return len(sw.clients)
 }
 
 func (sw *SlidingWindowLimiter) cleanup() {
@@ -59,15 +77,57 @@ func (sw *SlidingWindowLimiter) cleanup() {
 	}
 }
 
-func (sw *SlidingWindowLimiter) startCleanup() {
-	ticker := time.NewTicker(time.Minute)
+func (sw *SlidingWindowLimiter) Allow(apiKey string) RateLimitResult {
+	res, _ := sw.AllowN(context.Background(), apiKey, 1)
+	return res
+}
+
+func (sw *SlidingWindowLimiter) AllowN(_ context.Context, apiKey string, n int) (RateLimitResult, error) {
+	res, _ := sw.allowN(apiKey, normalizeCost(n))
+	return res, nil
+}
 
-	for range ticker.C {
-		sw.cleanup()
+func (sw *SlidingWindowLimiter) Reserve(ctx context.Context, apiKey string) (*Reservation, error) {
+	return sw.ReserveN(ctx, apiKey, 1)
+}
+
+func (sw *SlidingWindowLimiter) ReserveN(_ context.Context, apiKey string, n int) (*Reservation, error) {
+	n = normalizeCost(n)
+	res, id := sw.allowN(apiKey, n)
+
+	return newReservation(res, 0, func(context.Context) error {
+		sw.release(apiKey, id)
+		return nil
+	}), nil
+}
+
+func (sw *SlidingWindowLimiter) Wait(ctx context.Context, apiKey string) error {
+	return sw.WaitN(ctx, apiKey, 1)
+}
+
+func (sw *SlidingWindowLimiter) WaitN(ctx context.Context, apiKey string, n int) error {
+	return waitN(ctx, sw, sw.clock, apiKey, n)
+}
+
+func (sw *SlidingWindowLimiter) release(apiKey string, id uint64) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	state, ok := sw.clients[apiKey]
+	if !ok {
+		return
+	}
+
+	kept := state.entries[:0]
+	for _, e := range state.entries {
+		if e.id != id {
+			kept = append(kept, e)
+		}
 	}
+	state.entries = kept
 }
 
-func (sw *SlidingWindowLimiter) Allow(apiKey string) RateLimitResult {
+func (sw *SlidingWindowLimiter) allowN(apiKey string, n int) (RateLimitResult, uint64) {
 	sw.mu.Lock()
 	defer sw.mu.Unlock()
 
@@ -84,7 +144,7 @@ func (sw *SlidingWindowLimiter) Allow(apiKey string) RateLimitResult {
 			capHint = 0
 		}
 		state = &slidingWindowState{
-			timestamps: make([]time.Time, 0, capHint),
+			entries: make([]slidingWindowEntry, 0, capHint),
 			lastSeen: now,
 		}
 		sw.clients[apiKey] = state
@@ -93,28 +153,108 @@ func (sw *SlidingWindowLimiter) Allow(apiKey string) RateLimitResult {
 
 	windowStart := now.Add(-cfg.Window)
 
-	valid := state.timestamps[:0]
-	for _, ts := range state.timestamps {
-		if !ts.Before(windowStart) {
-			valid = append(valid, ts)
+	valid := state.entries[:0]
+	for _, e := range state.entries {
+		if !e.at.Before(windowStart) {
+			valid = append(valid, e)
 		}
 	}
-	state.timestamps = valid
+	state.entries = valid
+
+	if len(state.entries)+n > cfg.Limit {
+		// n units fit once enough of the oldest timestamps have expired.
+		resetAt := now.Add(cfg.Window)
+		if i := len(state.entries) + n - cfg.Limit - 1; i < len(state.entries) {
+			resetAt = state.entries[i].at.Add(cfg.Window)
+		}
+
+		remaining := cfg.Limit - len(state.entries)
+		if remaining < 0 {
+			remaining = 0
+		}
 
-	if len(state.timestamps) >= cfg.Limit {
 		return RateLimitResult{
 			Allowed: false,
-			Remaining: 0,
-			ResetAt: state.timestamps[0].Add(cfg.Window),
+			Remaining: remaining,
+			ResetAt: resetAt,
 			Limit: cfg.Limit,
-		}
+			Window: cfg.Window,
+			RetryAfter: resetAt.Sub(now),
+		}, 0
 	}
 
-	state.timestamps = append(state.timestamps, now)
+	sw.lastID++
+	for i := 0; i < n; i++ {
+		state.entries = append(state.entries, slidingWindowEntry{at: now, id: sw.lastID})
+	}
 	return RateLimitResult{
 		Allowed: true,
-		Remaining: cfg.Limit - len(state.timestamps),
-		ResetAt: state.timestamps[0].Add(cfg.Window),
+		Remaining: cfg.Limit - len(state.entries),
+		ResetAt: state.entries[0].at.Add(cfg.Window),
 		Limit: cfg.Limit,
+		Window: cfg.Window,
+	}, sw.lastID
+}
+
+func (sw *SlidingWindowLimiter) Inspect(_ context.Context, apiKey string) (KeyState, error) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	return sw.inspectLocked(apiKey, sw.clock.Now()), nil
+}
+
+func (sw *SlidingWindowLimiter) inspectLocked(apiKey string, now time.Time) KeyState {
+	cfg := sw.configFor(apiKey)
+	windowStart := now.Add(-cfg.Window)
+
+	timestamps := []time.Time{}
+	if state, ok := sw.clients[apiKey]; ok {
+		for _, e := range state.entries {
+			if !e.at.Before(windowStart) {
+				timestamps = append(timestamps, e.at)
+			}
+		}
+	}
+
+	resetAt := now
+	if len(timestamps) > 0 {
+		resetAt = timestamps[len(timestamps)-1].Add(cfg.Window)
 	}
+
+	return KeyState{
+		Key:        apiKey,
+		Limit:      cfg.Limit,
+		Window:     cfg.Window,
+		Used:       float64(len(timestamps)),
+		Remaining:  max(cfg.Limit-len(timestamps), 0),
+		ResetAt:    resetAt,
+		Timestamps: timestamps,
+	}
+}
+
+func (sw *SlidingWindowLimiter) Reset(_ context.Context, apiKey string) error {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	delete(sw.clients, apiKey)
+	return nil
+}
+
+func (sw *SlidingWindowLimiter) TopKeys(_ context.Context, n int, owns func(apiKey string) bool) ([]KeyState, error) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	now := sw.clock.Now()
+
+	var states []KeyState
+	for apiKey := range sw.clients {
+		if owns != nil && !owns(apiKey) {
+			continue
+		}
+		if state := sw.inspectLocked(apiKey, now); state.Used > 0 {
+			states = append(states, state)
+		}
+	}
+
+	return TopByUsage(states, n), nil
 }
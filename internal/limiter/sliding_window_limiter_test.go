@@ -1,6 +1,7 @@
 package limiter
 
 import (
+	"context"
 	"testing"
 	"time"
 )
@@ -121,3 +122,71 @@ func TestSlidingWindow_IsPerClient(t *testing.T) {
 		t.Fatalf("expected apiKey2 first request to be allowed independently")
 	}
 }
+
+func TestSlidingWindow_AllowNCountsCost(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	limiter := NewSlidingWindowLimiter(
+		clock,
+		LimitConfig{Limit: 4, Window: time.Second},
+		nil,
+	)
+
+	res, _ := limiter.AllowN(context.Background(), "test-key", 3)
+	if !res.Allowed || res.Remaining != 1 {
+		t.Fatalf("expected cost of 3 to leave 1 remaining, got %+v", res)
+	}
+
+	clock.Advance(500 * time.Millisecond)
+
+	res, _ = limiter.AllowN(context.Background(), "test-key", 2)
+	if res.Allowed {
+		t.Fatalf("expected request exceeding remaining budget to be rejected")
+	}
+
+	if want := clock.Now().Add(500 * time.Millisecond); !res.ResetAt.Equal(want) {
+		t.Fatalf("expected reset when the first batch expires at %v, got %v", want, res.ResetAt)
+	}
+}
+
+func TestSlidingWindow_CleanupRunsOnTheClockUntilClosed(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	limiter := NewSlidingWindowLimiterWithCleanupInterval(
+		clock,
+		LimitConfig{Limit: 5, Window: time.Second},
+		nil,
+		10*time.Second,
+	)
+
+	limiter.Allow("idle-key")
+	for clock.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	clock.Advance(9500 * time.Millisecond)
+	limiter.Allow("active-key")
+	if got := limiter.TrackedKeys(); got != 2 {
+		t.Fatalf("expected no cleanup before the interval, got %d keys", got)
+	}
+
+	clock.Advance(500 * time.Millisecond)
+	deadline := time.Now().Add(time.Second)
+	for limiter.TrackedKeys() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the idle key to be dropped, got %d keys", limiter.TrackedKeys())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := limiter.Close(); err != nil {
+		t.Fatalf("expected Close to succeed, got %v", err)
+	}
+
+	clock.Advance(time.Minute)
+	if got := limiter.TrackedKeys(); got != 1 {
+		t.Fatalf("expected no cleanup after Close, got %d keys", got)
+	}
+
+	if err := limiter.Close(); err != nil {
+		t.Fatalf("expected a second Close to succeed, got %v", err)
+	}
+}
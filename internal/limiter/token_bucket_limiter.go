@@ -1,6 +1,7 @@
 package limiter
 
 import (
+	"context"
 	"sync"
 	"time"
 )
@@ -13,9 +14,9 @@ type tokenBucketState struct {
 type TokenBucketLimiter struct {
 	mu           sync.Mutex
 	clients      map[string]*tokenBucketState
-	defaultLimit LimitConfig
-	overrides    map[string]LimitConfig
+	limits       *Limits
 	clock Clock
+	cleanupLoop *cleanupLoop
 }
 
 func NewTokenBucketLimiter(
@@ -23,27 +24,46 @@ func NewTokenBucketLimiter(
 	defaultLimit LimitConfig,
 	overrides map[string]LimitConfig,
 ) *TokenBucketLimiter {
-	if overrides == nil {
-		overrides = make(map[string]LimitConfig)
-	}
+	return NewTokenBucketLimiterWithCleanupInterval(clock, defaultLimit, overrides, defaultCleanupInterval)
+}
 
+// Buckets untouched for longer than their window have refilled completely, so
+// cleanup drops them.
+func NewTokenBucketLimiterWithCleanupInterval(
+	clock Clock,
+	defaultLimit LimitConfig,
+	overrides map[string]LimitConfig,
+	interval time.Duration,
+) *TokenBucketLimiter {
 	tb := &TokenBucketLimiter{
 		clients:      make(map[string]*tokenBucketState),
-		defaultLimit: defaultLimit,
-		overrides:    overrides,
+		limits:       NewLimits(defaultLimit, overrides),
 		clock: clock,
 	}
 
-	go tb.startCleanup()
+	tb.cleanupLoop = startCleanup(clock, interval, tb.cleanup)
 
 	return tb
 }
 
+func (tb *TokenBucketLimiter) Close() error {
+	tb.cleanupLoop.stop()
+	return nil
+}
+
 func (tb *TokenBucketLimiter) configFor(apiKey string) LimitConfig {
-	if cfg, ok := tb.overrides[apiKey]; ok {
-		return cfg
-	}
-	return tb.defaultLimit
+	return tb.limits.For(apiKey)
+}
+
+func (tb *TokenBucketLimiter) SetLimits(defaultLimit LimitConfig, overrides map[string]LimitConfig) {
+	tb.limits.Set(defaultLimit, overrides)
+}
+
+func (tb *TokenBucketLimiter) TrackedKeys() int {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	return len(tb.clients)
 }
 
 func (tb *TokenBucketLimiter) cleanup() {
@@ -60,15 +80,14 @@ func (tb *TokenBucketLimiter) cleanup() {
 	}
 }
 
-func (tb *TokenBucketLimiter) startCleanup() {
-	ticker := time.NewTicker(time.Minute)
-
-	for range ticker.C {
-		tb.cleanup()
-	}
+func (tb *TokenBucketLimiter) Allow(apiKey string) RateLimitResult {
+	res, _ := tb.AllowN(context.Background(), apiKey, 1)
+	return res
 }
 
-func (tb *TokenBucketLimiter) Allow(apiKey string) RateLimitResult {
+func (tb *TokenBucketLimiter) AllowN(_ context.Context, apiKey string, n int) (RateLimitResult, error) {
+	n = normalizeCost(n)
+
 	tb.mu.Lock()
 	defer tb.mu.Unlock()
 
@@ -77,17 +96,11 @@ func (tb *TokenBucketLimiter) Allow(apiKey string) RateLimitResult {
 
 	state, exists := tb.clients[apiKey]
 	if !exists {
-		tb.clients[apiKey] = &tokenBucketState{
-			tokens:     float64(cfg.Limit - 1),
+		state = &tokenBucketState{
+			tokens:     float64(cfg.Limit),
 			lastRefill: now,
 		}
-
-		return RateLimitResult{
-			Allowed:   true,
-			Remaining: cfg.Limit - 1,
-			Limit:     cfg.Limit,
-			ResetAt:   now.Add(cfg.Window),
-		}
+		tb.clients[apiKey] = state
 	}
 
 	elapsed := now.Sub(state.lastRefill)
@@ -97,21 +110,102 @@ func (tb *TokenBucketLimiter) Allow(apiKey string) RateLimitResult {
 	state.tokens = min(state.tokens+refilled, float64(cfg.Limit))
 	state.lastRefill = now
 
-	if state.tokens < 1 {
+	if state.tokens < float64(n) {
 		return RateLimitResult{
-			Allowed:   false,
-			Remaining: 0,
-			Limit:     cfg.Limit,
-			ResetAt:   now.Add(cfg.Window),
-		}
+			Allowed:    false,
+			Remaining:  int(state.tokens),
+			Limit:      cfg.Limit,
+			Window:     cfg.Window,
+			ResetAt:    now.Add(cfg.Window),
+			RetryAfter: secondsToDuration((float64(n) - state.tokens) / refillRate),
+		}, nil
 	}
 
-	state.tokens--
+	state.tokens -= float64(n)
 
 	return RateLimitResult{
 		Allowed:   true,
 		Remaining: int(state.tokens),
 		Limit:     cfg.Limit,
+		Window:    cfg.Window,
 		ResetAt:   now.Add(cfg.Window),
+	}, nil
+}
+
+func (tb *TokenBucketLimiter) Reserve(ctx context.Context, apiKey string) (*Reservation, error) {
+	return tb.ReserveN(ctx, apiKey, 1)
+}
+
+func (tb *TokenBucketLimiter) ReserveN(ctx context.Context, apiKey string, n int) (*Reservation, error) {
+	n = normalizeCost(n)
+	res, _ := tb.AllowN(ctx, apiKey, n)
+
+	return newReservation(res, 0, func(context.Context) error {
+		tb.release(apiKey, n)
+		return nil
+	}), nil
+}
+
+func (tb *TokenBucketLimiter) Wait(ctx context.Context, apiKey string) error {
+	return tb.WaitN(ctx, apiKey, 1)
+}
+
+func (tb *TokenBucketLimiter) WaitN(ctx context.Context, apiKey string, n int) error {
+	return waitN(ctx, tb, tb.clock, apiKey, n)
+}
+
+func (tb *TokenBucketLimiter) release(apiKey string, n int) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	if state, ok := tb.clients[apiKey]; ok {
+		state.tokens = min(state.tokens+float64(n), float64(tb.configFor(apiKey).Limit))
 	}
 }
+
+func (tb *TokenBucketLimiter) Inspect(_ context.Context, apiKey string) (KeyState, error) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	return tb.inspectLocked(apiKey, tb.clock.Now()), nil
+}
+
+func (tb *TokenBucketLimiter) inspectLocked(apiKey string, now time.Time) KeyState {
+	cfg := tb.configFor(apiKey)
+	capacity := float64(cfg.Limit)
+
+	tokens := capacity
+	if state, ok := tb.clients[apiKey]; ok {
+		refillRate := capacity / cfg.Window.Seconds()
+		tokens = min(state.tokens+refillRate*now.Sub(state.lastRefill).Seconds(), capacity)
+	}
+
+	return bucketState(apiKey, cfg, tokens, now)
+}
+
+func (tb *TokenBucketLimiter) Reset(_ context.Context, apiKey string) error {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	delete(tb.clients, apiKey)
+	return nil
+}
+
+func (tb *TokenBucketLimiter) TopKeys(_ context.Context, n int, owns func(apiKey string) bool) ([]KeyState, error) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	now := tb.clock.Now()
+
+	var states []KeyState
+	for apiKey := range tb.clients {
+		if owns != nil && !owns(apiKey) {
+			continue
+		}
+		if state := tb.inspectLocked(apiKey, now); state.Used > 0 {
+			states = append(states, state)
+		}
+	}
+
+	return TopByUsage(states, n), nil
+}
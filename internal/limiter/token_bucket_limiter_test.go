@@ -1,6 +1,7 @@
 package limiter
 
 import (
+	"context"
 	"testing"
 	"time"
 )
@@ -117,3 +118,63 @@ func TestTokenBucketDeniesWhenEmpty(t *testing.T) {
 		t.Fatalf("expected denial when bucket is empty")
 	}
 }
+
+func TestTokenBucketAllowNConsumesCost(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	limiter := NewTokenBucketLimiter(
+		clock,
+		LimitConfig{Limit: 5, Window: time.Minute},
+		nil,
+	)
+
+	res, _ := limiter.AllowN(context.Background(), "test-key", 4)
+	if !res.Allowed || res.Remaining != 1 {
+		t.Fatalf("expected cost of 4 to leave 1 token, got %+v", res)
+	}
+
+	if res, _ := limiter.AllowN(context.Background(), "test-key", 2); res.Allowed {
+		t.Fatalf("expected request costing more than remaining tokens to be denied")
+	}
+
+	if res := limiter.Allow("test-key"); !res.Allowed {
+		t.Fatalf("expected denied request not to consume tokens")
+	}
+}
+
+func TestTokenBucketAllowNAboveCapacityDenied(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	limiter := NewTokenBucketLimiter(
+		clock,
+		LimitConfig{Limit: 2, Window: time.Minute},
+		nil,
+	)
+
+	if res, _ := limiter.AllowN(context.Background(), "test-key", 3); res.Allowed {
+		t.Fatalf("expected cost above capacity to be denied")
+	}
+}
+
+func TestTokenBucketCleanupDropsRefilledBuckets(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	limiter := NewTokenBucketLimiterWithCleanupInterval(
+		clock,
+		LimitConfig{Limit: 5, Window: time.Second},
+		nil,
+		time.Minute,
+	)
+	defer limiter.Close()
+
+	limiter.Allow("test-key")
+	for clock.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	clock.Advance(time.Minute)
+	deadline := time.Now().Add(time.Second)
+	for limiter.TrackedKeys() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the refilled bucket to be dropped")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
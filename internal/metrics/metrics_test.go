@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegistryWritesTextExposition(t *testing.T) {
+	reg := NewRegistry()
+
+	decisions := reg.NewCounter("decisions_total", "Decisions made.", "decision", "route")
+	decisions.Inc("allowed", "")
+	decisions.Inc("allowed", "")
+	decisions.Inc("denied", `POST /a"b`)
+
+	latency := reg.NewHistogram("latency_seconds", "Latency.", []float64{0.1, 0.01})
+	latency.Observe(0.005)
+	latency.Observe(0.05)
+	latency.Observe(1)
+
+	keys := reg.NewGauge("tracked_keys", "Keys held\nin memory.", "source")
+	keys.SetFunc(func() float64 { return 42 }, "memory_store")
+
+	var out strings.Builder
+	reg.Write(&out)
+
+	want := `# HELP decisions_total Decisions made.
+# TYPE decisions_total counter
+decisions_total{decision="allowed",route=""} 2
+decisions_total{decision="denied",route="POST /a\"b"} 1
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.01"} 1
+latency_seconds_bucket{le="0.1"} 2
+latency_seconds_bucket{le="+Inf"} 3
+latency_seconds_sum 1.055
+latency_seconds_count 3
+# HELP tracked_keys Keys held\nin memory.
+# TYPE tracked_keys gauge
+tracked_keys{source="memory_store"} 42
+`
+	if out.String() != want {
+		t.Fatalf("unexpected exposition:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestRegisterTwicePanics(t *testing.T) {
+	reg := NewRegistry()
+	reg.NewCounter("requests_total", "Requests.")
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected a duplicate name to panic")
+		}
+	}()
+	reg.NewGauge("requests_total", "Requests.")
+}
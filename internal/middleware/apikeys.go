@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/bellettati/go-rate-limited-api/internal/apikeys"
+	"github.com/bellettati/go-rate-limited-api/internal/clientkey"
+	"github.com/bellettati/go-rate-limited-api/internal/limiter"
+)
+
+type KeyRegistry interface {
+	Lookup(ctx context.Context, apiKey string) (apikeys.Key, error)
+}
+
+type TierFunc func(tier, key string) limiter.Limiter
+
+// When the registry cannot be reached the failure mode decides, and found is
+// false if the request goes on.
+func checkAPIKey(o options, w http.ResponseWriter, r *http.Request, rlog *requestLog) (k apikeys.Key, found, ok bool) {
+	raw := strings.TrimSpace(r.Header.Get("X-API-Key"))
+	if raw == "" {
+		o.reject(w, r, Denial{Status: http.StatusUnauthorized, Detail: "missing API key"})
+		rlog.log(slog.LevelInfo, "request rejected", slog.Bool("allowed", false), slog.Int("status", http.StatusUnauthorized), slog.String("reason", "missing key"))
+		return apikeys.Key{}, false, false
+	}
+
+	k, err := o.apiKeys.Lookup(r.Context(), raw)
+	switch {
+	case errors.Is(err, apikeys.ErrUnknownKey):
+		o.reject(w, r, Denial{Status: http.StatusUnauthorized, Detail: "unknown API key"})
+		rlog.log(slog.LevelInfo, "request rejected",
+			slog.Bool("allowed", false),
+			slog.Int("status", http.StatusUnauthorized),
+			slog.String("reason", "unknown key"),
+			slog.String("api_key", clientkey.Mask(raw)),
+		)
+		return apikeys.Key{}, false, false
+
+	case err != nil:
+		degraded := "fail_" + string(o.failureMode)
+		w.Header().Set("X-RateLimit-Degraded", degraded)
+
+		status, level := http.StatusOK, slog.LevelWarn
+		if o.failureMode == FailClosed {
+			status, level = o.failureStatus, slog.LevelError
+			o.reject(w, r, Denial{Status: status, Detail: "API key registry unavailable"})
+		}
+
+		rlog.log(level, "API key registry unavailable",
+			slog.Int("status", status),
+			slog.String("degraded", degraded),
+			slog.Any("error", err),
+		)
+
+		return apikeys.Key{}, false, o.failureMode != FailClosed
+	}
+
+	rlog.with(slog.String("owner", k.Owner))
+	if k.Tier != "" {
+		rlog.with(slog.String("tier", k.Tier))
+	}
+
+	if k.Status != apikeys.StatusActive {
+		o.reject(w, r, Denial{Status: http.StatusForbidden, Detail: "API key revoked"})
+		rlog.log(slog.LevelInfo, "request rejected",
+			slog.Bool("allowed", false),
+			slog.Int("status", http.StatusForbidden),
+			slog.String("reason", "revoked key"),
+			slog.String("api_key", clientkey.Mask(raw)),
+		)
+		return k, true, false
+	}
+
+	return k, true, true
+}
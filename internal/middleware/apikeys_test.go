@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bellettati/go-rate-limited-api/internal/apikeys"
+	"github.com/bellettati/go-rate-limited-api/internal/limiter"
+)
+
+func TestAPIKeysRejectUnknownAndRevokedKeysAndPickTheTier(t *testing.T) {
+	ctx := context.Background()
+	reg := apikeys.NewRegistry(apikeys.NewMemoryStore(), apikeys.RegistryConfig{})
+
+	partnerKey, _, err := reg.Issue(ctx, "acme", "partner")
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+	revokedKey, revoked, _ := reg.Issue(ctx, "gone", "")
+	if _, err := reg.Revoke(ctx, revoked.Hash); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+
+	clock := limiter.NewFakeClock(time.Now())
+	def := limiter.NewTokenBucketLimiter(clock, limiter.LimitConfig{Limit: 1, Window: time.Minute}, nil)
+	partner := limiter.NewTokenBucketLimiter(clock, limiter.LimitConfig{Limit: 100, Window: time.Minute}, nil)
+	t.Cleanup(func() {
+		_ = def.Close()
+		_ = partner.Close()
+	})
+
+	h := RateLimit(def, WithAPIKeys(reg, func(tier, _ string) limiter.Limiter {
+		if tier == "partner" {
+			return partner
+		}
+		return nil
+	}))(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	status := func(apiKey string) (int, string) {
+		req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code, rec.Header().Get("X-RateLimit-Limit")
+	}
+
+	for apiKey, want := range map[string]int{
+		"":            http.StatusUnauthorized,
+		"rl_made_up":  http.StatusUnauthorized,
+		revokedKey:    http.StatusForbidden,
+		"another_one": http.StatusUnauthorized,
+	} {
+		if got, _ := status(apiKey); got != want {
+			t.Fatalf("expected %d for %q, got %d", want, apiKey, got)
+		}
+	}
+
+	for range 3 {
+		if got, limit := status(partnerKey); got != http.StatusOK || limit != "100" {
+			t.Fatalf("expected the partner tier's limit of 100, got %d with limit %q", got, limit)
+		}
+	}
+}
+
+func TestAPIKeysFileNeverReactivatesRevokedKeys(t *testing.T) {
+	ctx := context.Background()
+	reg := apikeys.NewRegistry(apikeys.NewMemoryStore(), apikeys.RegistryConfig{})
+	apiKey := "rl_seeded"
+	file := `{"keys": [{"hash": "` + apikeys.Hash(apiKey) + `", "owner": "acme"}]}`
+
+	seed := func() {
+		t.Helper()
+		keys, err := apikeys.Parse(strings.NewReader(file))
+		if err != nil {
+			t.Fatalf("parse: %v", err)
+		}
+		for _, k := range keys {
+			if _, err := reg.Add(ctx, k); err != nil {
+				t.Fatalf("add: %v", err)
+			}
+		}
+	}
+
+	seed()
+	if _, err := reg.Revoke(ctx, apikeys.Hash(apiKey)); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	seed()
+
+	clock := limiter.NewFakeClock(time.Now())
+	def := limiter.NewTokenBucketLimiter(clock, limiter.LimitConfig{Limit: 10, Window: time.Minute}, nil)
+	t.Cleanup(func() { _ = def.Close() })
+
+	h := RateLimit(def, WithAPIKeys(reg, nil))(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+	req.Header.Set("X-API-Key", apiKey)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected the reseeded key to stay revoked with 403, got %d", rec.Code)
+	}
+}
+
+func TestAllowlistedAddressesStillNeedAValidKey(t *testing.T) {
+	ctx := context.Background()
+	reg := apikeys.NewRegistry(apikeys.NewMemoryStore(), apikeys.RegistryConfig{})
+	validKey, _, _ := reg.Issue(ctx, "acme", "")
+	revokedKey, revoked, _ := reg.Issue(ctx, "gone", "")
+	if _, err := reg.Revoke(ctx, revoked.Hash); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+
+	clock := limiter.NewFakeClock(time.Now())
+	def := limiter.NewTokenBucketLimiter(clock, limiter.LimitConfig{Limit: 1, Window: time.Minute}, nil)
+	t.Cleanup(func() { _ = def.Close() })
+
+	rules := NewIPRules([]netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")}, nil)
+	h := RateLimit(def, WithIPRules(rules), WithAPIKeys(reg, nil))(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	status := func(apiKey string) int {
+		req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+		req.RemoteAddr = "192.0.2.10:1234"
+		req.Header.Set("X-API-Key", apiKey)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if got := status("rl_made_up"); got != http.StatusUnauthorized {
+		t.Fatalf("expected an unknown key from an allowed address to get 401, got %d", got)
+	}
+	if got := status(revokedKey); got != http.StatusForbidden {
+		t.Fatalf("expected a revoked key from an allowed address to get 403, got %d", got)
+	}
+	for range 3 {
+		if got := status(validKey); got != http.StatusOK {
+			t.Fatalf("expected a valid key from an allowed address to skip the limit, got %d", got)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/bellettati/go-rate-limited-api/internal/clientkey"
+	"github.com/bellettati/go-rate-limited-api/internal/limiter"
+)
+
+// The caller must call release once the request is done.
+func acquireSlot(c *limiter.ConcurrencyLimiter, o options, w http.ResponseWriter, r *http.Request, apiKey string, rlog *requestLog) (release func(), ok bool) {
+	lease, err := c.Acquire(r.Context(), apiKey)
+	if err != nil {
+		degraded := "fail_" + string(o.failureMode)
+		w.Header().Set("X-RateLimit-Degraded", degraded)
+
+		status, level := http.StatusOK, slog.LevelWarn
+		if o.failureMode == FailClosed {
+			status, level = o.failureStatus, slog.LevelError
+			o.reject(w, r, Denial{Status: status, Detail: "rate limiter unavailable"})
+		}
+
+		rlog.log(level, "concurrency limiter unavailable",
+			slog.Int("status", status),
+			slog.String("degraded", degraded),
+			slog.Any("error", err),
+		)
+
+		return func() {}, o.failureMode != FailClosed
+	}
+
+	res := lease.Result()
+	if res.Limit > 0 {
+		w.Header().Set("X-ConcurrencyLimit-Limit", strconv.Itoa(res.Limit))
+		w.Header().Set("X-ConcurrencyLimit-Remaining", strconv.Itoa(res.Remaining))
+	}
+
+	if !lease.OK() {
+		o.reject(w, r, Denial{Status: http.StatusTooManyRequests, Detail: "too many concurrent requests", Result: res})
+		o.metrics.decided(false, "concurrency", "")
+
+		rlog.log(slog.LevelInfo, "request rejected",
+			slog.Bool("allowed", false),
+			slog.Int("status", http.StatusTooManyRequests),
+			slog.String("reason", "concurrency"),
+			slog.Int("concurrency_limit", res.Limit),
+		)
+
+		return nil, false
+	}
+
+	if res.Limit > 0 {
+		go lease.KeepAlive(func(err error) {
+			o.logger.Warn("concurrency renew failed", slog.String("api_key", clientkey.Mask(apiKey)), slog.Any("error", err))
+		})
+	}
+
+	return func() {
+		if err := lease.Release(context.WithoutCancel(r.Context())); err != nil {
+			rlog.log(slog.LevelWarn, "concurrency release failed", slog.Any("error", err))
+		}
+	}, true
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import "net/http"
+
+// Values below 1 are charged as 1.
+type CostFunc func(r *http.Request) int
+
+type costHandler int
+
+func (costHandler) ServeHTTP(http.ResponseWriter, *http.Request) {}
+
+// RouteCosts panics on invalid or conflicting patterns, like
+// http.ServeMux.Handle.
+func RouteCosts(costs map[string]int) CostFunc {
+	mux := http.NewServeMux()
+	for pattern, cost := range costs {
+		mux.Handle(pattern, costHandler(cost))
+	}
+
+	return func(r *http.Request) int {
+		h, _ := mux.Handler(r)
+		if cost, ok := h.(costHandler); ok {
+			return int(cost)
+		}
+
+		return 1
+	}
+}
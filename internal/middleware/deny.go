@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bellettati/go-rate-limited-api/internal/limiter"
+)
+
+type Denial struct {
+	Status int
+	Detail string
+	// Result is zero when the request was rejected before a limiter was
+	// consulted.
+	Result     limiter.RateLimitResult
+	RetryAfter time.Duration
+	RequestID  string
+}
+
+// The rate limit headers are already set when a DenyHandler is called.
+type DenyHandler func(w http.ResponseWriter, r *http.Request, d Denial)
+
+type problem struct {
+	Type       string `json:"type"`
+	Title      string `json:"title"`
+	Status     int    `json:"status"`
+	Detail     string `json:"detail"`
+	Instance   string `json:"instance"`
+	Limit      *int   `json:"limit,omitempty"`
+	Remaining  *int   `json:"remaining,omitempty"`
+	Reset      *int64 `json:"reset,omitempty"`
+	RetryAfter *int64 `json:"retry_after,omitempty"`
+	Policy     string `json:"policy,omitempty"`
+	RequestID  string `json:"request_id,omitempty"`
+}
+
+func (o options) reject(w http.ResponseWriter, r *http.Request, d Denial) {
+	d.RequestID = r.Header.Get("X-Request-ID")
+	o.deny(w, r, d)
+}
+
+// Clients that accept JSON get an RFC 9457 application/problem+json body.
+func WriteProblem(w http.ResponseWriter, r *http.Request, d Denial) {
+	if !acceptsJSON(r.Header.Values("Accept")) {
+		http.Error(w, d.Detail, d.Status)
+		return
+	}
+
+	p := problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(d.Status),
+		Status:    d.Status,
+		Detail:    d.Detail,
+		Instance:  r.URL.Path,
+		RequestID: d.RequestID,
+	}
+
+	if res := d.Result; res.Limit > 0 {
+		remaining := max(res.Remaining, 0)
+		reset := res.ResetAt.Unix()
+		p.Limit, p.Remaining, p.Reset = &res.Limit, &remaining, &reset
+		if res.Window > 0 {
+			p.Policy = policyName(res.Window)
+		}
+	}
+
+	if d.RetryAfter > 0 {
+		secs := ceilSeconds(d.RetryAfter)
+		p.RetryAfter = &secs
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(d.Status)
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// Wildcards alone do not count, so clients that never asked keep plain text.
+func acceptsJSON(accept []string) bool {
+	for _, value := range accept {
+		for _, part := range strings.Split(value, ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil {
+				continue
+			}
+
+			if q, err := strconv.ParseFloat(params["q"], 64); err == nil && q == 0 {
+				continue
+			}
+
+			switch mediaType {
+			case "application/problem+json", "application/json", "application/*":
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bellettati/go-rate-limited-api/internal/limiter"
+)
+
+type HeaderStyle string
+
+const (
+	HeadersLegacy HeaderStyle = "legacy"
+	HeadersIETF   HeaderStyle = "ietf"
+	HeadersBoth   HeaderStyle = "both"
+)
+
+func writeRateLimitHeaders(h http.Header, style HeaderStyle, result limiter.RateLimitResult, now time.Time) {
+	if style != HeadersIETF {
+		h.Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		h.Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		h.Set("X-RateLimit-Reset", strconv.FormatInt(result.ResetAt.Unix(), 10))
+		if result.Window > 0 {
+			h.Set("X-RateLimit-Window", strconv.FormatInt(int64(result.Window/time.Second), 10))
+		}
+	}
+
+	if style == HeadersIETF || style == HeadersBoth {
+		windows := result.Windows
+		if len(windows) == 0 {
+			windows = []limiter.RateLimitResult{result}
+		}
+
+		policies := make([]string, 0, len(windows))
+		states := make([]string, 0, len(windows))
+		for _, w := range windows {
+			name := policyName(w.Window)
+
+			policy := fmt.Sprintf("%q;q=%d", name, w.Limit)
+			if w.Window > 0 {
+				policy += fmt.Sprintf(";w=%d", ceilSeconds(w.Window))
+			}
+			policies = append(policies, policy)
+
+			states = append(states, fmt.Sprintf("%q;r=%d;t=%d", name, max(w.Remaining, 0), ceilSeconds(w.ResetAt.Sub(now))))
+		}
+
+		h.Set("RateLimit-Policy", strings.Join(policies, ", "))
+		h.Set("RateLimit", strings.Join(states, ", "))
+	}
+}
+
+// Retry-After is sent in whole seconds and never less than one.
+func retryAfter(result limiter.RateLimitResult, now time.Time) time.Duration {
+	wait := result.RetryAfter
+	if wait <= 0 {
+		wait = result.ResetAt.Sub(now)
+	}
+
+	return time.Duration(max(ceilSeconds(wait), 1)) * time.Second
+}
+
+func policyName(window time.Duration) string {
+	switch {
+	case window <= 0:
+		return "default"
+	case window%time.Hour == 0:
+		return fmt.Sprintf("%dh", window/time.Hour)
+	case window%time.Minute == 0:
+		return fmt.Sprintf("%dm", window/time.Minute)
+	default:
+		return fmt.Sprintf("%ds", ceilSeconds(window))
+	}
+}
+
+func ceilSeconds(d time.Duration) int64 {
+	if d <= 0 {
+		return 0
+	}
+
+	return int64((d + time.Second - 1) / time.Second)
+}
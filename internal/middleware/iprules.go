@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"net/netip"
+)
+
+type IPAction string
+
+const (
+	IPAllow IPAction = "allow"
+	IPDeny  IPAction = "deny"
+)
+
+type ipRule struct {
+	prefix netip.Prefix
+	action IPAction
+}
+
+type ipNode struct {
+	child [2]*ipNode
+	rule  *ipRule
+}
+
+type IPRules struct {
+	v4, v6 *ipNode
+}
+
+// The most specific matching prefix decides; a prefix listed as both allowed
+// and denied is denied.
+func NewIPRules(allow, deny []netip.Prefix) *IPRules {
+	rules := &IPRules{v4: &ipNode{}, v6: &ipNode{}}
+	for _, p := range allow {
+		rules.insert(p, IPAllow)
+	}
+	for _, p := range deny {
+		rules.insert(p, IPDeny)
+	}
+
+	return rules
+}
+
+func (rules *IPRules) root(ip netip.Addr) *ipNode {
+	if ip.Is4() {
+		return rules.v4
+	}
+
+	return rules.v6
+}
+
+func (rules *IPRules) insert(p netip.Prefix, action IPAction) {
+	if p.Addr().Is4In6() && p.Bits() >= 96 {
+		p = netip.PrefixFrom(p.Addr().Unmap(), p.Bits()-96)
+	}
+	p = p.Masked()
+
+	n := rules.root(p.Addr())
+	bytes := p.Addr().AsSlice()
+	for i := 0; i < p.Bits(); i++ {
+		b := bit(bytes, i)
+		if n.child[b] == nil {
+			n.child[b] = &ipNode{}
+		}
+		n = n.child[b]
+	}
+
+	if n.rule == nil || action == IPDeny {
+		n.rule = &ipRule{prefix: p, action: action}
+	}
+}
+
+func (rules *IPRules) Match(ip netip.Addr) (IPAction, netip.Prefix, bool) {
+	if rules == nil || !ip.IsValid() {
+		return "", netip.Prefix{}, false
+	}
+
+	ip = ip.Unmap()
+	n := rules.root(ip)
+	match := n.rule
+	bytes := ip.AsSlice()
+	for i := 0; i < ip.BitLen() && n != nil; i++ {
+		n = n.child[bit(bytes, i)]
+		if n != nil && n.rule != nil {
+			match = n.rule
+		}
+	}
+
+	if match == nil {
+		return "", netip.Prefix{}, false
+	}
+
+	return match.action, match.prefix, true
+}
+
+func bit(bytes []byte, i int) int {
+	return int(bytes[i/8]>>(7-i%8)) & 1
+}
+
+// An allowlist match skips the limits but not the API key check.
+func checkIP(o options, w http.ResponseWriter, r *http.Request, rlog *requestLog) (allowed, ok bool) {
+	ip, found := clientAddr(r, o.trustedProxies)
+	if !found {
+		return false, true
+	}
+
+	action, prefix, found := o.ipRules.Match(ip)
+	if !found {
+		return false, true
+	}
+
+	rlog.with(slog.String("ip", ip.String()), slog.String("ip_rule", string(action)+":"+prefix.String()))
+
+	if action == IPDeny {
+		o.reject(w, r, Denial{Status: http.StatusForbidden, Detail: "forbidden"})
+		rlog.log(slog.LevelInfo, "request rejected", slog.Bool("allowed", false), slog.Int("status", http.StatusForbidden))
+		return false, false
+	}
+
+	return true, true
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"fmt"
+	"net/netip"
+	"testing"
+)
+
+func TestIPRulesMostSpecificPrefixWins(t *testing.T) {
+	rules := NewIPRules(
+		[]netip.Prefix{
+			netip.MustParsePrefix("10.0.0.0/8"),
+			netip.MustParsePrefix("198.51.100.7/32"),
+			netip.MustParsePrefix("2001:db8::/32"),
+		},
+		[]netip.Prefix{
+			netip.MustParsePrefix("10.66.0.0/16"),
+			netip.MustParsePrefix("198.51.100.0/24"),
+			netip.MustParsePrefix("2001:db8:bad::/48"),
+			netip.MustParsePrefix("10.0.0.0/8"),
+			netip.MustParsePrefix("::ffff:192.0.2.0/120"),
+		},
+	)
+
+	tests := []struct {
+		ip         string
+		wantAction IPAction
+		wantPrefix string
+	}{
+		{ip: "10.1.2.3", wantAction: IPDeny, wantPrefix: "10.0.0.0/8"},
+		{ip: "10.66.1.1", wantAction: IPDeny, wantPrefix: "10.66.0.0/16"},
+		{ip: "198.51.100.7", wantAction: IPAllow, wantPrefix: "198.51.100.7/32"},
+		{ip: "198.51.100.8", wantAction: IPDeny, wantPrefix: "198.51.100.0/24"},
+		{ip: "::ffff:198.51.100.7", wantAction: IPAllow, wantPrefix: "198.51.100.7/32"},
+		{ip: "192.0.2.9", wantAction: IPDeny, wantPrefix: "192.0.2.0/24"},
+		{ip: "2001:db8:1::1", wantAction: IPAllow, wantPrefix: "2001:db8::/32"},
+		{ip: "2001:db8:bad::1", wantAction: IPDeny, wantPrefix: "2001:db8:bad::/48"},
+		{ip: "203.0.113.1"},
+		{ip: "2001:db9::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			action, prefix, ok := rules.Match(netip.MustParseAddr(tt.ip))
+			if tt.wantAction == "" {
+				if ok {
+					t.Fatalf("expected no match, got %s %s", action, prefix)
+				}
+				return
+			}
+
+			if !ok || action != tt.wantAction || prefix.String() != tt.wantPrefix {
+				t.Fatalf("expected %s %s, got %s %s (matched=%v)", tt.wantAction, tt.wantPrefix, action, prefix, ok)
+			}
+		})
+	}
+}
+
+func BenchmarkIPRulesMatch(b *testing.B) {
+	deny := make([]netip.Prefix, 0, 10000)
+	for i := 0; i < 10000; i++ {
+		deny = append(deny, netip.MustParsePrefix(fmt.Sprintf("10.%d.%d.0/24", i/256, i%256)))
+	}
+	rules := NewIPRules(nil, deny)
+	ip := netip.MustParseAddr("10.20.30.40")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rules.Match(ip)
+	}
+}
@@ -0,0 +1,217 @@
+package middleware
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// KeyFuncs tag every key with its source ("key:", "ip:" …), so an API key of
+// "ip:203.0.113.5" is never counted against that address.
+type KeyFunc func(r *http.Request) string
+
+func APIKey() KeyFunc {
+	return tagged("key:", func(r *http.Request) string {
+		return r.Header.Get("X-API-Key")
+	})
+}
+
+func Header(name string) KeyFunc {
+	return tagged("header:"+http.CanonicalHeaderKey(name)+"=", func(r *http.Request) string {
+		return r.Header.Get(name)
+	})
+}
+
+func QueryParam(name string) KeyFunc {
+	return tagged("query:"+name+"=", func(r *http.Request) string {
+		return r.URL.Query().Get(name)
+	})
+}
+
+func tagged(tag string, value func(r *http.Request) string) KeyFunc {
+	return func(r *http.Request) string {
+		if v := strings.TrimSpace(value(r)); v != "" {
+			return tag + v
+		}
+
+		return ""
+	}
+}
+
+// BearerSubject does not check the token signature; it must sit behind
+// whatever authenticates the token.
+func BearerSubject() KeyFunc {
+	return func(r *http.Request) string {
+		scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			return ""
+		}
+
+		parts := strings.Split(strings.TrimSpace(token), ".")
+		if len(parts) != 3 {
+			return ""
+		}
+
+		payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+		if err != nil {
+			return ""
+		}
+
+		var claims struct {
+			Subject string `json:"sub"`
+		}
+		if err := json.Unmarshal(payload, &claims); err != nil || claims.Subject == "" {
+			return ""
+		}
+
+		return "sub:" + claims.Subject
+	}
+}
+
+// ClientIP reads forwarding headers from the right, skipping trusted proxies.
+func ClientIP(trusted ...netip.Prefix) KeyFunc {
+	return func(r *http.Request) string {
+		ip, ok := clientAddr(r, trusted)
+		if !ok {
+			return ""
+		}
+
+		return "ip:" + ip.String()
+	}
+}
+
+func clientAddr(r *http.Request, trusted []netip.Prefix) (netip.Addr, bool) {
+	ip, ok := remoteIP(r.RemoteAddr)
+	if !ok || !isTrusted(ip, trusted) {
+		return ip, ok
+	}
+
+	hops := forwardedFor(r.Header)
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, ok := parseHop(hops[i])
+		if !ok {
+			// Anything left of a malformed entry may be forged.
+			break
+		}
+
+		ip = hop
+		if !isTrusted(hop, trusted) {
+			break
+		}
+	}
+
+	return ip, true
+}
+
+func RoutePattern(patterns ...string) KeyFunc {
+	mux := newPatternMux(patterns)
+
+	return func(r *http.Request) string {
+		if p, ok := matchPattern(mux, r); ok {
+			return "route:" + p
+		}
+
+		return "route:*"
+	}
+}
+
+var compositeEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`)
+
+// Composite escapes "|" and "\" so parts cannot run into each other.
+func Composite(fns ...KeyFunc) KeyFunc {
+	return func(r *http.Request) string {
+		parts := make([]string, 0, len(fns))
+		for _, fn := range fns {
+			part := fn(r)
+			if part == "" {
+				return ""
+			}
+			parts = append(parts, compositeEscaper.Replace(part))
+		}
+
+		return strings.Join(parts, "|")
+	}
+}
+
+func FirstOf(fns ...KeyFunc) KeyFunc {
+	return func(r *http.Request) string {
+		for _, fn := range fns {
+			if key := fn(r); key != "" {
+				return key
+			}
+		}
+
+		return ""
+	}
+}
+
+func remoteIP(addr string) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+
+	return ip.Unmap().WithZone(""), true
+}
+
+func isTrusted(ip netip.Addr, trusted []netip.Prefix) bool {
+	for _, p := range trusted {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func forwardedFor(h http.Header) []string {
+	if values := h.Values("Forwarded"); len(values) > 0 {
+		var hops []string
+		for _, elem := range strings.Split(strings.Join(values, ","), ",") {
+			hop := ""
+			for _, pair := range strings.Split(elem, ";") {
+				k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(k, "for") {
+					hop = strings.Trim(v, `"`)
+				}
+			}
+			hops = append(hops, hop)
+		}
+
+		return hops
+	}
+
+	var hops []string
+	for _, v := range h.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+
+	return hops
+}
+
+func parseHop(hop string) (netip.Addr, bool) {
+	hop = strings.TrimSpace(hop)
+	if ip, err := netip.ParseAddr(hop); err == nil {
+		return ip.Unmap().WithZone(""), true
+	}
+
+	if ap, err := netip.ParseAddrPort(hop); err == nil {
+		return ap.Addr().Unmap().WithZone(""), true
+	}
+
+	if strings.HasPrefix(hop, "[") && strings.HasSuffix(hop, "]") {
+		if ip, err := netip.ParseAddr(hop[1 : len(hop)-1]); err == nil {
+			return ip.Unmap().WithZone(""), true
+		}
+	}
+
+	return netip.Addr{}, false
+}
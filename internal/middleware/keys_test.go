@@ -0,0 +1,164 @@
+package middleware
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	}
+
+	tests := []struct {
+		name    string
+		remote  string
+		headers map[string]string
+		want    string
+	}{
+		{
+			name:   "peer address without proxy",
+			remote: "203.0.113.7:5555",
+			want:   "ip:203.0.113.7",
+		},
+		{
+			name:    "untrusted peer cannot spoof X-Forwarded-For",
+			remote:  "203.0.113.7:5555",
+			headers: map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			want:    "ip:203.0.113.7",
+		},
+		{
+			name:    "trusted proxy",
+			remote:  "10.0.0.2:80",
+			headers: map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			want:    "ip:198.51.100.1",
+		},
+		{
+			name:    "spoofed leftmost entry is ignored",
+			remote:  "10.0.0.2:80",
+			headers: map[string]string{"X-Forwarded-For": "1.2.3.4, 198.51.100.1, 10.0.0.3"},
+			want:    "ip:198.51.100.1",
+		},
+		{
+			name:    "Forwarded wins over X-Forwarded-For",
+			remote:  "[2001:db8::1]:443",
+			headers: map[string]string{"Forwarded": `for="[2001:db8:cafe::17]:4711", for=192.0.2.60;proto=https`, "X-Forwarded-For": "1.2.3.4"},
+			want:    "ip:192.0.2.60",
+		},
+		{
+			name:    "only trusted hops",
+			remote:  "10.0.0.2:80",
+			headers: map[string]string{"X-Forwarded-For": "10.1.1.1"},
+			want:    "ip:10.1.1.1",
+		},
+		{
+			name:    "malformed hop stops the walk",
+			remote:  "10.0.0.2:80",
+			headers: map[string]string{"Forwarded": "for=198.51.100.1, for=unknown"},
+			want:    "ip:10.0.0.2",
+		},
+		{
+			name:   "IPv4-mapped IPv6 peer",
+			remote: "[::ffff:203.0.113.7]:5555",
+			want:   "ip:203.0.113.7",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remote
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+
+			if got := ClientIP(trusted...)(r); got != tt.want {
+				t.Fatalf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestBearerSubject(t *testing.T) {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"user-42","exp":1}`))
+
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{name: "jwt", header: "Bearer eyJhbGciOiJub25lIn0." + payload + ".sig", want: "sub:user-42"},
+		{name: "lowercase scheme", header: "bearer x." + payload + ".y", want: "sub:user-42"},
+		{name: "opaque token", header: "Bearer abc123", want: ""},
+		{name: "basic auth", header: "Basic dXNlcjpwYXNz", want: ""},
+		{name: "missing", header: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+
+			if got := BearerSubject()(r); got != tt.want {
+				t.Fatalf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCompositeAndFirstOf(t *testing.T) {
+	key := FirstOf(
+		Composite(APIKey(), RoutePattern("POST /orders")),
+		QueryParam("client"),
+	)
+
+	r := httptest.NewRequest(http.MethodPost, "/orders?client=c1", nil)
+	r.Header.Set("X-API-Key", "k1")
+	if got := key(r); got != "key:k1|route:POST /orders" {
+		t.Fatalf("expected composite key, got %q", got)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/orders?client=c1", nil)
+	r.Header.Set("X-API-Key", "k1")
+	if got := key(r); got != "key:k1|route:*" {
+		t.Fatalf("expected unmatched route to key as *, got %q", got)
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/orders?client=c1", nil)
+	if got := key(r); got != "query:client=c1" {
+		t.Fatalf("expected fallback to the query param, got %q", got)
+	}
+
+	// The separator inside a part is escaped, so this key cannot pose as
+	// k1 on another route.
+	r = httptest.NewRequest(http.MethodGet, "/orders", nil)
+	r.Header.Set("X-API-Key", `k1|route:POST /orders\`)
+	if got := key(r); got != `key:k1\|route:POST /orders\\|route:*` {
+		t.Fatalf("expected the separator to be escaped, got %q", got)
+	}
+}
+
+func TestKeysAreTaggedWithTheirSource(t *testing.T) {
+	key := FirstOf(APIKey(), Header("X-Client-ID"), ClientIP())
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "203.0.113.5:4711"
+	anonymous := key(r)
+
+	r.Header.Set("X-API-Key", anonymous)
+	if got := key(r); got == anonymous || got != "key:"+anonymous {
+		t.Fatalf("expected an API key spelled like an address to stay an API key, got %q", got)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("x-client-id", "c1")
+	if got := key(r); got != "header:X-Client-Id=c1" {
+		t.Fatalf("expected a tagged header key, got %q", got)
+	}
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// An incoming request ID is kept; otherwise one is generated.
+const RequestIDHeader = "X-Request-ID"
+
+func requestID(w http.ResponseWriter, r *http.Request) string {
+	id := r.Header.Get(RequestIDHeader)
+	if !validRequestID(id) {
+		var b [16]byte
+		_, _ = rand.Read(b[:])
+		id = hex.EncodeToString(b[:])
+		r.Header.Set(RequestIDHeader, id)
+	}
+
+	w.Header().Set(RequestIDHeader, id)
+	return id
+}
+
+// Request IDs are checked so a client cannot inject anything odd into logs or
+// response headers.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+type requestLog struct {
+	logger *slog.Logger
+	r      *http.Request
+	start  time.Time
+	attrs  []slog.Attr
+}
+
+func newRequestLog(logger *slog.Logger, r *http.Request, id string) *requestLog {
+	return &requestLog{
+		logger: logger,
+		r:      r,
+		start:  time.Now(),
+		attrs: []slog.Attr{
+			slog.String("request_id", id),
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+		},
+	}
+}
+
+func (l *requestLog) with(attrs ...slog.Attr) {
+	l.attrs = append(l.attrs, attrs...)
+}
+
+func (l *requestLog) log(level slog.Level, msg string, attrs ...slog.Attr) {
+	all := make([]slog.Attr, 0, len(l.attrs)+len(attrs)+1)
+	all = append(all, l.attrs...)
+	all = append(all, attrs...)
+	all = append(all, slog.Duration("duration", time.Since(l.start)))
+
+	l.logger.LogAttrs(l.r.Context(), level, msg, all...)
+}
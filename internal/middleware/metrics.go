@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/bellettati/go-rate-limited-api/internal/metrics"
+)
+
+type Metrics struct {
+	decisions *metrics.Counter
+	latency   *metrics.Histogram
+}
+
+func NewMetrics(reg *metrics.Registry) *Metrics {
+	return &Metrics{
+		decisions: reg.NewCounter(
+			"ratelimit_decisions_total",
+			"Rate limit decisions by outcome, policy window and route.",
+			"decision", "policy", "route",
+		),
+		latency: reg.NewHistogram(
+			"ratelimit_decision_duration_seconds",
+			"Time the limiter took to decide, excluding time spent queued.",
+			metrics.DefaultBuckets,
+		),
+	}
+}
+
+func (m *Metrics) decided(allowed bool, policy, route string) {
+	if m == nil {
+		return
+	}
+
+	decision := "denied"
+	if allowed {
+		decision = "allowed"
+	}
+	m.decisions.Inc(decision, policy, route)
+}
+
+func (m *Metrics) timed(took time.Duration) {
+	if m == nil {
+		return
+	}
+
+	m.latency.Observe(took.Seconds())
+}
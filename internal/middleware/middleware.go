@@ -1,12 +1,15 @@
 package middleware
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/bellettati/go-rate-limited-api/internal/apikeys"
+	"github.com/bellettati/go-rate-limited-api/internal/clientkey"
 	"github.com/bellettati/go-rate-limited-api/internal/limiter"
+	"github.com/bellettati/go-rate-limited-api/internal/store"
 )
 
 type StatusRecorder struct {
@@ -14,82 +17,190 @@ type StatusRecorder struct {
 	status int
 }
 
-func maskAPIKey(key string) string {
-	if len(key) <= 4 {
-		return "****"
-	}
-
-	return key[:2] + "****" + key[len(key)-2:]
-}
-
 func (sr *StatusRecorder) WriteHeader(code int) {
 	sr.status = code
 	sr.ResponseWriter.WriteHeader(code)
 }
 
-func RateLimit(l limiter.Limiter) func(http.Handler) http.Handler {
+func (sr *StatusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
+
+func RateLimit(l limiter.Limiter, opts ...Option) func(http.Handler) http.Handler {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if r.URL.Path == "/health" {
+			rlog := newRequestLog(o.logger, r, requestID(w, r))
+
+			var allowlisted bool
+			if o.ipRules != nil {
+				var ok bool
+				if allowlisted, ok = checkIP(o, w, r, rlog); !ok {
+					return
+				}
+			}
+
+			if o.exempt != nil && o.exempt(r) {
 				next.ServeHTTP(w, r)
 				return
 			}
 
-			start := time.Now()
+			var registered apikeys.Key
+			var found bool
+			if o.apiKeys != nil {
+				var ok bool
+				if registered, found, ok = checkAPIKey(o, w, r, rlog); !ok {
+					return
+				}
+			}
 
-			recorder := &StatusRecorder{
-				ResponseWriter: w,
-				status:         http.StatusOK,
+			if allowlisted {
+				recorder := &StatusRecorder{ResponseWriter: w, status: http.StatusOK}
+				next.ServeHTTP(recorder, r)
+				rlog.log(slog.LevelInfo, "request allowed", slog.Bool("allowed", true), slog.Int("status", recorder.status))
+				return
 			}
 
-			apiKey := r.Header.Get("X-API-Key")
+			apiKey := o.key(r)
 			if apiKey == "" {
-				http.Error(recorder, "missing API key", http.StatusUnauthorized)
-
-				log.Printf(
-					"method=%s path=%s apiKey=missing allowed=false status=%d duration=%s",
-					r.Method,
-					r.URL.Path,
-					http.StatusUnauthorized,
-					time.Since(start),
-				)
+				o.reject(w, r, Denial{Status: http.StatusUnauthorized, Detail: "missing API key"})
+				rlog.log(slog.LevelInfo, "request rejected", slog.Bool("allowed", false), slog.Int("status", http.StatusUnauthorized), slog.String("reason", "missing key"))
 
 				return
 			}
+			rlog.with(slog.String("api_key", clientkey.Mask(apiKey)))
+
+			if o.concurrency != nil {
+				release, ok := acquireSlot(o.concurrency, o, w, r, apiKey, rlog)
+				if !ok {
+					return
+				}
+				defer release()
+			}
+
+			cost := o.cost(r)
 
-			result := l.Allow(apiKey)
+			rl, route := l, ""
+			if found && o.tiers != nil {
+				if tiered := o.tiers(registered.Tier, apiKey); tiered != nil {
+					rl = tiered
+				}
+			}
+			if o.routes != nil {
+				if pattern, routed := o.routes(r); routed != nil {
+					rl, route = routed, pattern
+				}
+			}
 
-			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
-			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
-			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(result.ResetAt.Unix(), 10))
+			ctx, calls := store.WithCallStats(r.Context())
+			var (
+				result limiter.RateLimitResult
+				queued time.Duration
+				err    error
+			)
+			decideStart := time.Now()
+			if o.queueing {
+				result, queued, err = queue(ctx, rl, apiKey, cost)
+			} else {
+				result, err = rl.AllowN(ctx, apiKey, cost)
+			}
+			o.metrics.timed(time.Since(decideStart) - queued)
+
+			rlog.with(slog.Int("cost", cost))
+			if route != "" {
+				rlog.with(slog.String("route", route))
+			}
+			policy := ""
+			if result.Limit > 0 {
+				policy = policyName(result.Window)
+				rlog.with(slog.String("policy", policy))
+			}
+			if calls.Calls() > 0 {
+				rlog.with(slog.Duration("store_latency", calls.Duration()), slog.Int("store_calls", calls.Calls()))
+			}
+
+			// The client is gone; there is nobody left to answer.
+			if err != nil && r.Context().Err() != nil {
+				rlog.log(slog.LevelInfo, "client went away", slog.Any("error", err))
+				return
+			}
+
+			degraded := ""
+			if result.Fallback {
+				degraded = "fallback"
+				w.Header().Set("X-RateLimit-Degraded", degraded)
+			}
+
+			if err != nil {
+				degraded = "fail_" + string(o.failureMode)
+				w.Header().Set("X-RateLimit-Degraded", degraded)
+				rlog.with(slog.String("degraded", degraded), slog.Any("error", err))
+
+				if o.failureMode == FailClosed {
+					o.reject(w, r, Denial{Status: o.failureStatus, Detail: "rate limiter unavailable", Result: result})
+					o.metrics.decided(false, policy, route)
+					rlog.log(slog.LevelError, "rate limiter unavailable, request rejected", slog.Bool("allowed", false), slog.Int("status", o.failureStatus))
+
+					return
+				}
+
+				rlog.log(slog.LevelWarn, "rate limiter unavailable, request let through")
+
+				result.Allowed = true
+				result.Remaining = result.Limit
+			} else if degraded != "" {
+				rlog.with(slog.String("degraded", degraded))
+			}
+
+			// A limiter that failed before reading its config (e.g. a queued
+			// reservation) has no limit to report.
+			now := time.Now()
+			if result.Limit > 0 {
+				writeRateLimitHeaders(w.Header(), o.headers, result, now)
+			}
 
 			if !result.Allowed {
-				http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
-
-				log.Printf(
-					"method=%s path=%s apiKey=%s allowed=false status=%d remaining=%d duration=%s",
-					r.Method,
-					r.URL.Path,
-					maskAPIKey(apiKey),
-					http.StatusTooManyRequests,
-					result.Remaining,
-					time.Since(start),
+				wait := retryAfter(result, now)
+				w.Header().Set("Retry-After", strconv.FormatInt(int64(wait/time.Second), 10))
+				o.reject(w, r, Denial{
+					Status:     http.StatusTooManyRequests,
+					Detail:     "rate limit exceeded",
+					Result:     result,
+					RetryAfter: wait,
+				})
+				o.metrics.decided(false, policy, route)
+
+				rlog.log(slog.LevelInfo, "request rejected",
+					slog.Bool("allowed", false),
+					slog.Int("status", http.StatusTooManyRequests),
+					slog.Int("remaining", result.Remaining),
+					slog.Duration("retry_after", wait),
 				)
 
 				return
 			}
 
-			log.Printf(
-				"method=%s path=%s apiKey=%s allowed=false status=%d remaining=%d duration=%s",
-				r.Method,
-				r.URL.Path,
-				maskAPIKey(apiKey),
-				http.StatusOK,
-				result.Remaining,
-				time.Since(start),
-			)
+			o.metrics.decided(true, policy, route)
 
-			next.ServeHTTP(w, r)
+			recorder := &StatusRecorder{
+				ResponseWriter: w,
+				status:         http.StatusOK,
+			}
+			next.ServeHTTP(recorder, r)
+
+			attrs := []slog.Attr{
+				slog.Bool("allowed", true),
+				slog.Int("status", recorder.status),
+				slog.Int("remaining", result.Remaining),
+			}
+			if queued > 0 {
+				attrs = append(attrs, slog.Duration("queued", queued))
+			}
+			rlog.log(slog.LevelInfo, "request allowed", attrs...)
 		})
 	}
 }
@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"net/netip"
+
+	"github.com/bellettati/go-rate-limited-api/internal/limiter"
+)
+
+type Option func(*options)
+
+type FailureMode string
+
+const (
+	FailOpen   FailureMode = "open"
+	FailClosed FailureMode = "closed"
+)
+
+type options struct {
+	key            KeyFunc
+	cost           CostFunc
+	failureMode    FailureMode
+	failureStatus  int
+	queueing       bool
+	concurrency    *limiter.ConcurrencyLimiter
+	exempt         Matcher
+	routes         RouteFunc
+	headers        HeaderStyle
+	deny           DenyHandler
+	logger         *slog.Logger
+	metrics        *Metrics
+	ipRules        *IPRules
+	trustedProxies []netip.Prefix
+	apiKeys        KeyRegistry
+	tiers          TierFunc
+}
+
+func defaultOptions() options {
+	return options{
+		key:           APIKey(),
+		cost:          func(*http.Request) int { return 1 },
+		failureMode:   FailOpen,
+		failureStatus: http.StatusServiceUnavailable,
+		exempt:        Patterns("/health"),
+		headers:       HeadersLegacy,
+		deny:          WriteProblem,
+		logger:        slog.Default(),
+	}
+}
+
+func WithKeyFunc(fn KeyFunc) Option {
+	return func(o *options) {
+		if fn != nil {
+			o.key = fn
+		}
+	}
+}
+
+func WithCost(fn CostFunc) Option {
+	return func(o *options) {
+		if fn != nil {
+			o.cost = fn
+		}
+	}
+}
+
+// FailClosed rejects with status, 503 when status is 0.
+func WithFailureMode(mode FailureMode, status int) Option {
+	return func(o *options) {
+		o.failureMode = mode
+		if status != 0 {
+			o.failureStatus = status
+		}
+	}
+}
+
+func WithQueueing() Option {
+	return func(o *options) {
+		o.queueing = true
+	}
+}
+
+func WithConcurrencyLimit(c *limiter.ConcurrencyLimiter) Option {
+	return func(o *options) {
+		o.concurrency = c
+	}
+}
+
+func WithExempt(m Matcher) Option {
+	return func(o *options) {
+		o.exempt = m
+	}
+}
+
+// Route limiters should keep their counters apart, e.g. on a
+// store.NewPrefixedStore of their own.
+func WithRoutes(fn RouteFunc) Option {
+	return func(o *options) {
+		o.routes = fn
+	}
+}
+
+func WithIPRules(rules *IPRules, trusted ...netip.Prefix) Option {
+	return func(o *options) {
+		o.ipRules = rules
+		o.trustedProxies = trusted
+	}
+}
+
+func WithAPIKeys(reg KeyRegistry, tiers TierFunc) Option {
+	return func(o *options) {
+		o.apiKeys = reg
+		o.tiers = tiers
+	}
+}
+
+func WithHeaders(style HeaderStyle) Option {
+	return func(o *options) {
+		if style != "" {
+			o.headers = style
+		}
+	}
+}
+
+func WithDenyHandler(fn DenyHandler) Option {
+	return func(o *options) {
+		if fn != nil {
+			o.deny = fn
+		}
+	}
+}
+
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		if logger != nil {
+			o.logger = logger
+		}
+	}
+}
+
+func WithMetrics(m *Metrics) Option {
+	return func(o *options) {
+		o.metrics = m
+	}
+}
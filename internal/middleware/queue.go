@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"github.com/bellettati/go-rate-limited-api/internal/limiter"
+)
+
+// If the request goes away while queued its reservation is cancelled; the
+// limiter decides whether the slot can be reused.
+func queue(ctx context.Context, l limiter.Limiter, apiKey string, cost int) (limiter.RateLimitResult, time.Duration, error) {
+	res, err := limiter.Reserve(ctx, l, apiKey, cost)
+	if err != nil {
+		return limiter.RateLimitResult{}, 0, err
+	}
+
+	if !res.OK() || res.Delay() <= 0 {
+		return res.Result(), 0, nil
+	}
+
+	timer := time.NewTimer(res.Delay())
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return res.Result(), res.Delay(), nil
+	case <-ctx.Done():
+		_ = res.Cancel(context.WithoutCancel(ctx))
+		return res.Result(), 0, ctx.Err()
+	}
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/bellettati/go-rate-limited-api/internal/limiter"
+)
+
+type Matcher func(r *http.Request) bool
+
+type RouteFunc func(r *http.Request) (pattern string, l limiter.Limiter)
+
+type patternHandler string
+
+func (patternHandler) ServeHTTP(http.ResponseWriter, *http.Request) {}
+
+func newPatternMux(patterns []string) *http.ServeMux {
+	mux := http.NewServeMux()
+	for _, p := range patterns {
+		mux.Handle(p, patternHandler(p))
+	}
+
+	return mux
+}
+
+func matchPattern(mux *http.ServeMux, r *http.Request) (string, bool) {
+	h, _ := mux.Handler(r)
+	p, ok := h.(patternHandler)
+	return string(p), ok
+}
+
+func Patterns(patterns ...string) Matcher {
+	mux := newPatternMux(patterns)
+
+	return func(r *http.Request) bool {
+		_, ok := matchPattern(mux, r)
+		return ok
+	}
+}
+
+func RouteLimiters(limiters map[string]limiter.Limiter) RouteFunc {
+	patterns := make([]string, 0, len(limiters))
+	for p := range limiters {
+		patterns = append(patterns, p)
+	}
+	mux := newPatternMux(patterns)
+
+	return func(r *http.Request) (string, limiter.Limiter) {
+		p, ok := matchPattern(mux, r)
+		if !ok {
+			return "", nil
+		}
+
+		return p, limiters[p]
+	}
+}
@@ -0,0 +1,75 @@
+package overrides
+
+import (
+	"context"
+	"sync"
+)
+
+const auditCap = 1000
+
+type MemoryStore struct {
+	mu        sync.Mutex
+	overrides map[string]Override
+	audit     []AuditEntry
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{overrides: make(map[string]Override)}
+}
+
+func (s *MemoryStore) Load(_ context.Context) (map[string]Override, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	loaded := make(map[string]Override, len(s.overrides))
+	for key, o := range s.overrides {
+		loaded[key] = o
+	}
+
+	return loaded, nil
+}
+
+func (s *MemoryStore) Save(_ context.Context, o Override) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.overrides[o.Key] = o
+	return nil
+}
+
+func (s *MemoryStore) Remove(_ context.Context, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.overrides[key]
+	delete(s.overrides, key)
+	return ok, nil
+}
+
+func (s *MemoryStore) AppendAudit(_ context.Context, e AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.audit = append(s.audit, e)
+	if len(s.audit) > auditCap {
+		s.audit = s.audit[len(s.audit)-auditCap:]
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) Audit(_ context.Context, n int) ([]AuditEntry, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make([]AuditEntry, 0, min(n, len(s.audit)))
+	for i := len(s.audit) - 1; i >= 0 && len(entries) < n; i-- {
+		entries = append(entries, s.audit[i])
+	}
+
+	return entries, nil
+}
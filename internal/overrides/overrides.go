@@ -0,0 +1,357 @@
+// Package overrides manages per-key limit overrides set through the admin API.
+package overrides
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bellettati/go-rate-limited-api/internal/clientkey"
+	"github.com/bellettati/go-rate-limited-api/internal/config"
+)
+
+type Override struct {
+	Key    string
+	Policy config.KeyPolicy
+	// ExpiresAt is the zero time for overrides that never expire.
+	ExpiresAt time.Time
+	Reason    string
+
+	CreatedBy string
+	CreatedAt time.Time
+	UpdatedBy string
+	UpdatedAt time.Time
+}
+
+func (o Override) expired(now time.Time) bool {
+	return !o.ExpiresAt.IsZero() && !now.Before(o.ExpiresAt)
+}
+
+type overrideJSON struct {
+	Key         string             `json:"key"`
+	Tier        string             `json:"tier,omitempty"`
+	Limits      []config.LimitJSON `json:"limits,omitempty"`
+	Concurrency int                `json:"concurrency,omitempty"`
+	ExpiresAt   *time.Time         `json:"expires_at,omitempty"`
+	Reason      string             `json:"reason,omitempty"`
+	CreatedBy   string             `json:"created_by"`
+	CreatedAt   time.Time          `json:"created_at"`
+	UpdatedBy   string             `json:"updated_by"`
+	UpdatedAt   time.Time          `json:"updated_at"`
+}
+
+func (o Override) MarshalJSON() ([]byte, error) {
+	v := overrideJSON{
+		Key:         o.Key,
+		Tier:        o.Policy.Tier,
+		Concurrency: o.Policy.Concurrency,
+		Reason:      o.Reason,
+		CreatedBy:   o.CreatedBy,
+		CreatedAt:   o.CreatedAt,
+		UpdatedBy:   o.UpdatedBy,
+		UpdatedAt:   o.UpdatedAt,
+	}
+	for _, l := range o.Policy.Limits {
+		v.Limits = append(v.Limits, config.LimitJSON{Limit: l.Limit, Window: l.Window.String()})
+	}
+	if !o.ExpiresAt.IsZero() {
+		v.ExpiresAt = &o.ExpiresAt
+	}
+
+	return json.Marshal(v)
+}
+
+func (o *Override) UnmarshalJSON(data []byte) error {
+	var v overrideJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	*o = Override{
+		Key:       v.Key,
+		Policy:    config.KeyPolicy{Tier: v.Tier, Concurrency: v.Concurrency},
+		Reason:    v.Reason,
+		CreatedBy: v.CreatedBy,
+		CreatedAt: v.CreatedAt,
+		UpdatedBy: v.UpdatedBy,
+		UpdatedAt: v.UpdatedAt,
+	}
+	if len(v.Limits) > 0 {
+		limits, err := config.ParseLimits("override "+v.Key, v.Limits)
+		if err != nil {
+			return err
+		}
+		o.Policy.Limits = limits
+	}
+	if v.ExpiresAt != nil {
+		o.ExpiresAt = *v.ExpiresAt
+	}
+
+	return nil
+}
+
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+	ActionExpire = "expire"
+)
+
+// SystemActor is recorded for changes nobody asked for, i.e. expiry.
+const SystemActor = "system"
+
+type AuditEntry struct {
+	At     time.Time `json:"at"`
+	Actor  string    `json:"actor"`
+	Action string    `json:"action"`
+	Key    string    `json:"key"`
+	Before *Override `json:"before,omitempty"`
+	After  *Override `json:"after,omitempty"`
+}
+
+type Store interface {
+	Load(ctx context.Context) (map[string]Override, error)
+	Save(ctx context.Context, o Override) error
+	// Remove reports whether key was there, so that when several replicas
+	// expire the same override only one of them records it.
+	Remove(ctx context.Context, key string) (bool, error)
+
+	AppendAudit(ctx context.Context, e AuditEntry) error
+	Audit(ctx context.Context, n int) ([]AuditEntry, error)
+}
+
+var (
+	ErrNotFound = errors.New("override not found")
+	ErrInvalid  = errors.New("invalid override")
+)
+
+type Manager struct {
+	st       Store
+	now      func() time.Time
+	apply    func(map[string]Override)
+	validate func(Override) error
+	logger   *slog.Logger
+
+	mu     sync.Mutex
+	active map[string]Override
+	timer  *time.Timer
+}
+
+type ManagerConfig struct {
+	Apply    func(map[string]Override)
+	Validate func(Override) error
+	Now      func() time.Time
+	Logger   *slog.Logger
+}
+
+func NewManager(st Store, cfg ManagerConfig) *Manager {
+	if cfg.Apply == nil {
+		cfg.Apply = func(map[string]Override) {}
+	}
+	if cfg.Validate == nil {
+		cfg.Validate = func(Override) error { return nil }
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+
+	return &Manager{
+		st:       st,
+		now:      cfg.Now,
+		apply:    cfg.Apply,
+		validate: cfg.Validate,
+		logger:   cfg.Logger,
+		active:   make(map[string]Override),
+	}
+}
+
+func (m *Manager) Sync(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	loaded, err := m.st.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("load overrides: %w", err)
+	}
+
+	now := m.now()
+	for key, o := range loaded {
+		if !o.expired(now) {
+			continue
+		}
+
+		delete(loaded, key)
+		removed, err := m.st.Remove(ctx, key)
+		if err != nil {
+			return fmt.Errorf("expire override %q: %w", key, err)
+		}
+		if removed {
+			m.audit(ctx, AuditEntry{At: now, Actor: SystemActor, Action: ActionExpire, Key: key, Before: &o})
+		}
+	}
+
+	m.active = loaded
+	m.publish()
+	return nil
+}
+
+func (m *Manager) Run(ctx context.Context, interval time.Duration, report func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := m.Sync(ctx); err != nil && ctx.Err() == nil {
+			report(err)
+		}
+	}
+}
+
+func (m *Manager) List() []Override {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	list := make([]Override, 0, len(m.active))
+	for _, o := range m.active {
+		if !o.expired(now) {
+			list = append(list, o)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+
+	return list
+}
+
+func (m *Manager) Get(key string) (Override, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	o, ok := m.active[key]
+	if !ok || o.expired(m.now()) {
+		return Override{}, false
+	}
+
+	return o, true
+}
+
+// Put keeps the creation fields of an existing override.
+func (m *Manager) Put(ctx context.Context, actor string, o Override) (saved Override, created bool, err error) {
+	if len(o.Policy.Limits) > 0 == (o.Policy.Tier != "") {
+		return Override{}, false, fmt.Errorf("%w: set either a tier or limits", ErrInvalid)
+	}
+	if err := m.validate(o); err != nil {
+		return Override{}, false, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	if !o.ExpiresAt.IsZero() && !o.ExpiresAt.After(now) {
+		return Override{}, false, fmt.Errorf("%w: expires_at must be in the future", ErrInvalid)
+	}
+
+	prev, exists := m.active[o.Key]
+	o.CreatedBy, o.CreatedAt = actor, now
+	if exists {
+		o.CreatedBy, o.CreatedAt = prev.CreatedBy, prev.CreatedAt
+	}
+	o.UpdatedBy, o.UpdatedAt = actor, now
+
+	if err := m.st.Save(ctx, o); err != nil {
+		return Override{}, false, fmt.Errorf("save override: %w", err)
+	}
+	m.active[o.Key] = o
+
+	entry := AuditEntry{At: now, Actor: actor, Action: ActionCreate, Key: o.Key, After: &o}
+	if exists {
+		entry.Action, entry.Before = ActionUpdate, &prev
+	}
+	m.audit(ctx, entry)
+
+	m.publish()
+	return o, !exists, nil
+}
+
+func (m *Manager) Delete(ctx context.Context, actor, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	prev, exists := m.active[key]
+	if !exists {
+		return ErrNotFound
+	}
+
+	if _, err := m.st.Remove(ctx, key); err != nil {
+		return fmt.Errorf("remove override: %w", err)
+	}
+	delete(m.active, key)
+
+	m.audit(ctx, AuditEntry{At: m.now(), Actor: actor, Action: ActionDelete, Key: key, Before: &prev})
+
+	m.publish()
+	return nil
+}
+
+func (m *Manager) Audit(ctx context.Context, n int) ([]AuditEntry, error) {
+	return m.st.Audit(ctx, n)
+}
+
+// A change is never undone because its audit entry could not be stored; the
+// log line remains.
+func (m *Manager) audit(ctx context.Context, e AuditEntry) {
+	logger := m.logger.With("actor", e.Actor, "action", e.Action, "api_key", clientkey.Mask(e.Key))
+	logger.Info("override changed")
+
+	if err := m.st.AppendAudit(ctx, e); err != nil {
+		logger.Error("override audit entry not stored", "error", err)
+	}
+}
+
+// publish arms a timer for the next expiry, so an override stops applying
+// when it expires rather than at the next Sync.
+func (m *Manager) publish() {
+	now := m.now()
+	snapshot := make(map[string]Override, len(m.active))
+	var next time.Time
+	for key, o := range m.active {
+		if o.expired(now) {
+			continue
+		}
+		snapshot[key] = o
+
+		if !o.ExpiresAt.IsZero() && (next.IsZero() || o.ExpiresAt.Before(next)) {
+			next = o.ExpiresAt
+		}
+	}
+
+	if m.timer != nil {
+		m.timer.Stop()
+	}
+	if !next.IsZero() {
+		m.timer = time.AfterFunc(next.Sub(now), m.expire)
+	}
+
+	m.apply(snapshot)
+}
+
+func (m *Manager) expire() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.publish()
+}
@@ -0,0 +1,165 @@
+package overrides
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bellettati/go-rate-limited-api/internal/config"
+)
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func newTestManager(t *testing.T) (*Manager, *MemoryStore, *testClock, *map[string]Override) {
+	t.Helper()
+
+	st := NewMemoryStore()
+	clock := &testClock{now: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	applied := map[string]Override{}
+
+	m := NewManager(st, ManagerConfig{
+		Apply: func(active map[string]Override) { applied = active },
+		Now:   clock.Now,
+	})
+
+	return m, st, clock, &applied
+}
+
+func TestManagerAuditsEveryChange(t *testing.T) {
+	m, _, clock, applied := newTestManager(t)
+	ctx := context.Background()
+
+	bump := Override{Key: "acme", Policy: config.KeyPolicy{Tier: "partner"}, Reason: "migration"}
+	if _, created, err := m.Put(ctx, "alice", bump); err != nil || !created {
+		t.Fatalf("expected the override to be created, got created=%v err=%v", created, err)
+	}
+	if _, ok := (*applied)["acme"]; !ok {
+		t.Fatalf("expected the new override to be applied")
+	}
+
+	clock.now = clock.now.Add(time.Hour)
+	bump.Policy = config.KeyPolicy{Limits: []config.LimitWindow{{Limit: 50, Window: time.Minute}}}
+	saved, created, err := m.Put(ctx, "bob", bump)
+	if err != nil || created {
+		t.Fatalf("expected the override to be updated, got created=%v err=%v", created, err)
+	}
+	if saved.CreatedBy != "alice" || saved.UpdatedBy != "bob" || !saved.UpdatedAt.Equal(clock.now) {
+		t.Fatalf("expected creation to be kept and the update recorded, got %+v", saved)
+	}
+
+	if err := m.Delete(ctx, "carol", "acme"); err != nil {
+		t.Fatalf("unexpected delete error: %v", err)
+	}
+	if err := m.Delete(ctx, "carol", "acme"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if len(*applied) != 0 {
+		t.Fatalf("expected no overrides to be applied, got %v", *applied)
+	}
+
+	trail, _ := m.Audit(ctx, 10)
+	want := []struct{ actor, action string }{{"carol", ActionDelete}, {"bob", ActionUpdate}, {"alice", ActionCreate}}
+	if len(trail) != len(want) {
+		t.Fatalf("expected %d audit entries, got %+v", len(want), trail)
+	}
+	for i, w := range want {
+		if trail[i].Actor != w.actor || trail[i].Action != w.action || trail[i].Key != "acme" {
+			t.Fatalf("entry %d: expected %s by %s, got %+v", i, w.action, w.actor, trail[i])
+		}
+	}
+	if trail[1].Before == nil || trail[1].Before.Policy.Tier != "partner" || trail[1].After == nil {
+		t.Fatalf("expected the update to record before and after, got %+v", trail[1])
+	}
+}
+
+func TestManagerExpiresOverridesOnSync(t *testing.T) {
+	m, st, clock, applied := newTestManager(t)
+	ctx := context.Background()
+
+	temp := Override{Key: "acme", Policy: config.KeyPolicy{Tier: "partner"}, ExpiresAt: clock.now.Add(48 * time.Hour)}
+	if _, _, err := m.Put(ctx, "alice", temp); err != nil {
+		t.Fatalf("unexpected put error: %v", err)
+	}
+
+	// Another replica adds an override straight to the shared store.
+	_ = st.Save(ctx, Override{Key: "globex", Policy: config.KeyPolicy{Tier: "free"}})
+
+	clock.now = clock.now.Add(48 * time.Hour)
+	if err := m.Sync(ctx); err != nil {
+		t.Fatalf("unexpected sync error: %v", err)
+	}
+
+	if _, ok := m.Get("acme"); ok {
+		t.Fatalf("expected the expired override to be gone")
+	}
+	if _, ok := (*applied)["globex"]; !ok || len(*applied) != 1 {
+		t.Fatalf("expected only the other replica's override to be applied, got %v", *applied)
+	}
+
+	trail, _ := m.Audit(ctx, 1)
+	if len(trail) != 1 || trail[0].Action != ActionExpire || trail[0].Actor != SystemActor {
+		t.Fatalf("expected the expiry to be audited, got %+v", trail)
+	}
+
+	if _, _, err := m.Put(ctx, "alice", Override{Key: "late", Policy: temp.Policy, ExpiresAt: clock.now}); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("expected an override that is already expired to be rejected")
+	}
+}
+
+func TestManagerStopsApplyingExpiredOverridesBeforeSync(t *testing.T) {
+	m, _, clock, applied := newTestManager(t)
+	ctx := context.Background()
+
+	temp := Override{Key: "acme", Policy: config.KeyPolicy{Tier: "partner"}, ExpiresAt: clock.now.Add(time.Hour)}
+	if _, _, err := m.Put(ctx, "alice", temp); err != nil {
+		t.Fatalf("unexpected put error: %v", err)
+	}
+	if m.timer == nil {
+		t.Fatalf("expected a timer for the override's expiry")
+	}
+	m.timer.Stop()
+
+	clock.now = clock.now.Add(time.Hour)
+	if _, ok := m.Get("acme"); ok {
+		t.Fatalf("expected an expired override not to be returned")
+	}
+	if list := m.List(); len(list) != 0 {
+		t.Fatalf("expected an expired override not to be listed, got %+v", list)
+	}
+
+	m.expire()
+	if len(*applied) != 0 {
+		t.Fatalf("expected the expired override to stop applying, got %v", *applied)
+	}
+}
+
+func TestOverrideJSONRoundTrip(t *testing.T) {
+	o := Override{
+		Key:       "acme",
+		Policy:    config.KeyPolicy{Limits: []config.LimitWindow{{Limit: 10, Window: time.Second}, {Limit: 1000, Window: time.Hour}}},
+		ExpiresAt: time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC),
+		CreatedBy: "alice",
+	}
+
+	data, err := json.Marshal(o)
+	if err != nil {
+		t.Fatalf("unexpected marshal error: %v", err)
+	}
+
+	var back Override
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatalf("unexpected unmarshal error: %v", err)
+	}
+
+	if back.Key != o.Key || !back.ExpiresAt.Equal(o.ExpiresAt) || len(back.Policy.Limits) != 2 || back.Policy.Limits[1] != o.Policy.Limits[1] {
+		t.Fatalf("expected %+v back, got %+v", o, back)
+	}
+}
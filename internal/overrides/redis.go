@@ -0,0 +1,90 @@
+package overrides
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	redisOverridesKey = "rl:overrides"
+	redisAuditKey     = "rl:overrides:audit"
+)
+
+type RedisStore struct {
+	client redis.UniversalClient
+}
+
+func NewRedisStore(client redis.UniversalClient) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func (s *RedisStore) Load(ctx context.Context) (map[string]Override, error) {
+	raw, err := s.client.HGetAll(ctx, redisOverridesKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	loaded := make(map[string]Override, len(raw))
+	for key, data := range raw {
+		var o Override
+		if err := json.Unmarshal([]byte(data), &o); err != nil {
+			return nil, fmt.Errorf("override %q: %w", key, err)
+		}
+		loaded[key] = o
+	}
+
+	return loaded, nil
+}
+
+func (s *RedisStore) Save(ctx context.Context, o Override) error {
+	data, err := json.Marshal(o)
+	if err != nil {
+		return err
+	}
+
+	return s.client.HSet(ctx, redisOverridesKey, o.Key, data).Err()
+}
+
+func (s *RedisStore) Remove(ctx context.Context, key string) (bool, error) {
+	n, err := s.client.HDel(ctx, redisOverridesKey, key).Result()
+	return n > 0, err
+}
+
+func (s *RedisStore) AppendAudit(ctx context.Context, e AuditEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.LPush(ctx, redisAuditKey, data)
+		p.LTrim(ctx, redisAuditKey, 0, auditCap-1)
+		return nil
+	})
+	return err
+}
+
+func (s *RedisStore) Audit(ctx context.Context, n int) ([]AuditEntry, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+
+	raw, err := s.client.LRange(ctx, redisAuditKey, 0, int64(n-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]AuditEntry, 0, len(raw))
+	for _, data := range raw {
+		var e AuditEntry
+		if err := json.Unmarshal([]byte(data), &e); err != nil {
+			return nil, fmt.Errorf("audit entry: %w", err)
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
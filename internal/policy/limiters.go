@@ -0,0 +1,330 @@
+// Package policy builds the limiters of the policy file and the overrides.
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bellettati/go-rate-limited-api/internal/apikeys"
+	"github.com/bellettati/go-rate-limited-api/internal/clientkey"
+	"github.com/bellettati/go-rate-limited-api/internal/config"
+	"github.com/bellettati/go-rate-limited-api/internal/limiter"
+	"github.com/bellettati/go-rate-limited-api/internal/middleware"
+	"github.com/bellettati/go-rate-limited-api/internal/overrides"
+	"github.com/bellettati/go-rate-limited-api/internal/store"
+)
+
+type Config struct {
+	Store       store.Store
+	Distributed bool
+	Fallback    store.Store
+	Replicas    int
+
+	Build  BuildFunc
+	Clock  limiter.Clock
+	Tiers  bool
+	Logger *slog.Logger
+
+	// 0 means no cap.
+	Concurrency int
+	LeaseTTL    time.Duration
+}
+
+// Reloads reconfigure the limiters in place, so counters survive as long as
+// their key, route or tier does.
+type Limiters struct {
+	cfg         Config
+	request     *scope
+	concurrency *limiter.ConcurrencyLimiter
+
+	// mu serializes reloads. The policy and the overrides both feed the key
+	// limits, so either changing re-applies both.
+	mu        sync.Mutex
+	policy    *config.Policy
+	overrides map[string]overrides.Override
+	routes    map[string]*scope
+	tiers     map[string]*scope
+
+	routing atomic.Pointer[routing]
+	tiered  atomic.Pointer[tierRouting]
+}
+
+type routing struct {
+	routes   map[string]*scope
+	route    middleware.RouteFunc
+	cost     middleware.CostFunc
+	exempt   middleware.Matcher
+	routeKey middleware.KeyFunc
+}
+
+type tierRouting struct {
+	tiers    map[string]*scope
+	explicit map[string]bool
+}
+
+func New(cfg Config, p *config.Policy) *Limiters {
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+
+	l := &Limiters{
+		cfg:         cfg,
+		routes:      make(map[string]*scope),
+		tiers:       make(map[string]*scope),
+		concurrency: limiter.NewConcurrencyLimiter(cfg.Store, cfg.Clock, limiter.LimitConfig{}, nil, cfg.LeaseTTL),
+	}
+	l.request = l.newScope("")
+	l.ApplyPolicy(p)
+
+	return l
+}
+
+func RouteScope(pattern string) string {
+	return "route:" + pattern
+}
+
+func TierScope(tier string) string {
+	return "tier:" + tier
+}
+
+// ApplyPolicy swaps in p. Route and tier limiters that survive keep their
+// counters; the others are closed.
+func (l *Limiters) ApplyPolicy(p *config.Policy) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.policy = p
+
+	if l.cfg.Tiers {
+		l.tiers = reconcile(l, l.tiers, p.Tiers, TierScope)
+	}
+	l.applyKeyLimits()
+
+	withLimits := make(map[string][]config.LimitWindow)
+	patterns := make([]string, 0, len(p.Routes))
+	for _, rule := range p.Routes {
+		patterns = append(patterns, rule.Pattern)
+		if len(rule.Limits) > 0 {
+			withLimits[rule.Pattern] = rule.Limits
+		}
+	}
+	l.routes = reconcile(l, l.routes, withLimits, RouteScope)
+
+	routed := make(map[string]limiter.Limiter, len(l.routes))
+	for pattern, s := range l.routes {
+		routed[pattern] = s.limiter
+	}
+
+	l.routing.Store(&routing{
+		routes:   l.routes,
+		route:    middleware.RouteLimiters(routed),
+		cost:     middleware.RouteCosts(p.RouteCosts()),
+		exempt:   middleware.Patterns(p.Exempt...),
+		routeKey: middleware.RoutePattern(patterns...),
+	})
+}
+
+func reconcile(l *Limiters, current map[string]*scope, limits map[string][]config.LimitWindow, name func(string) string) map[string]*scope {
+	next := make(map[string]*scope, len(limits))
+	for key, windows := range limits {
+		s, ok := current[key]
+		if !ok {
+			s = l.newScope(name(key))
+		}
+		s.setPolicy(limitConfigs(windows), nil)
+		next[key] = s
+	}
+
+	for key, s := range current {
+		if _, ok := next[key]; !ok {
+			_ = s.Close()
+		}
+	}
+
+	return next
+}
+
+func (l *Limiters) ApplyOverrides(active map[string]overrides.Override) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.overrides = active
+	l.applyKeyLimits()
+}
+
+func (l *Limiters) ValidateOverride(o overrides.Override) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.policy.Resolve(o.Policy); !ok {
+		return fmt.Errorf("unknown tier %q", o.Policy.Tier)
+	}
+
+	return nil
+}
+
+func (l *Limiters) ValidateKey(k apikeys.Key) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.policy.Tiers[k.Tier]; k.Tier != "" && !ok {
+		return fmt.Errorf("unknown tier %q", k.Tier)
+	}
+
+	return nil
+}
+
+// Keys are spelled as the middleware tags them, so a policy key "acme" is
+// the API key "key:acme".
+func (l *Limiters) applyKeyLimits() {
+	keyPolicies := make(map[string][]limiter.LimitConfig, len(l.policy.Keys)+len(l.overrides))
+	caps := make(map[string]int, len(l.policy.Keys)+len(l.overrides))
+	for key, kp := range l.policy.Keys {
+		windows, _ := l.policy.Resolve(kp)
+		keyPolicies[clientkey.Normalize(key)] = limitConfigs(windows)
+		caps[clientkey.Normalize(key)] = kp.Concurrency
+	}
+	for key, o := range l.overrides {
+		windows, ok := l.policy.Resolve(o.Policy)
+		if !ok {
+			l.cfg.Logger.Warn("override names a tier the policy does not have, ignoring it", "api_key", clientkey.Mask(key), "tier", o.Policy.Tier)
+			continue
+		}
+		keyPolicies[clientkey.Normalize(key)] = limitConfigs(windows)
+		caps[clientkey.Normalize(key)] = o.Policy.Concurrency
+	}
+	l.request.setPolicy(limitConfigs(l.policy.Default), keyPolicies)
+
+	defaultCap := l.policy.Concurrency
+	if defaultCap == 0 {
+		defaultCap = l.cfg.Concurrency
+	}
+	keyCaps := make(map[string]limiter.LimitConfig, len(caps))
+	for key, n := range caps {
+		if n > 0 {
+			keyCaps[key] = limiter.LimitConfig{Limit: n}
+		}
+	}
+	l.concurrency.SetLimits(limiter.LimitConfig{Limit: defaultCap}, keyCaps)
+
+	next := &tierRouting{tiers: l.tiers, explicit: make(map[string]bool, len(keyPolicies))}
+	for key := range keyPolicies {
+		next.explicit[key] = true
+	}
+	l.tiered.Store(next)
+}
+
+func (l *Limiters) Limiter() limiter.Limiter {
+	return l.request.limiter
+}
+
+func (l *Limiters) Concurrency() *limiter.ConcurrencyLimiter {
+	return l.concurrency
+}
+
+func (l *Limiters) Keys() limiter.Inspector {
+	return l.request
+}
+
+func (l *Limiters) Route(r *http.Request) (string, limiter.Limiter) {
+	return l.routing.Load().route(r)
+}
+
+func (l *Limiters) Cost(r *http.Request) int {
+	return l.routing.Load().cost(r)
+}
+
+func (l *Limiters) Exempt(r *http.Request) bool {
+	return l.routing.Load().exempt(r)
+}
+
+func (l *Limiters) RouteKey(r *http.Request) string {
+	return l.routing.Load().routeKey(r)
+}
+
+// Keys with limits of their own stay on the request limiter.
+func (l *Limiters) Tier(tier, key string) limiter.Limiter {
+	t := l.tiered.Load()
+	if t.explicit[key] {
+		return nil
+	}
+
+	if s, ok := t.tiers[tier]; ok {
+		return s.limiter
+	}
+
+	return nil
+}
+
+func (l *Limiters) Names() []string {
+	var names []string
+	for pattern := range l.routing.Load().routes {
+		names = append(names, RouteScope(pattern))
+	}
+	for tier := range l.tiered.Load().tiers {
+		names = append(names, TierScope(tier))
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func (l *Limiters) Inspector(name string) (limiter.Inspector, bool) {
+	if pattern, ok := strings.CutPrefix(name, "route:"); ok {
+		s, ok := l.routing.Load().routes[pattern]
+		return s, ok
+	}
+
+	if tier, ok := strings.CutPrefix(name, "tier:"); ok {
+		s, ok := l.tiered.Load().tiers[tier]
+		return s, ok
+	}
+
+	return nil, false
+}
+
+func (l *Limiters) TrackedKeys() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	total := l.request.TrackedKeys()
+	for _, s := range l.routes {
+		total += s.TrackedKeys()
+	}
+	for _, s := range l.tiers {
+		total += s.TrackedKeys()
+	}
+
+	return total
+}
+
+func (l *Limiters) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	errs := []error{l.request.Close()}
+	for _, s := range l.routes {
+		errs = append(errs, s.Close())
+	}
+	for _, s := range l.tiers {
+		errs = append(errs, s.Close())
+	}
+
+	return errors.Join(errs...)
+}
+
+func limitConfigs(windows []config.LimitWindow) []limiter.LimitConfig {
+	configs := make([]limiter.LimitConfig, 0, len(windows))
+	for _, w := range windows {
+		configs = append(configs, limiter.LimitConfig{Limit: w.Limit, Window: w.Window})
+	}
+
+	return configs
+}
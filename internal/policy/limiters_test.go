@@ -0,0 +1,257 @@
+package policy
+
+import (
+	"context"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bellettati/go-rate-limited-api/internal/config"
+	"github.com/bellettati/go-rate-limited-api/internal/limiter"
+	"github.com/bellettati/go-rate-limited-api/internal/overrides"
+	"github.com/bellettati/go-rate-limited-api/internal/store"
+)
+
+func newTestLimiters(t *testing.T, p *config.Policy) *Limiters {
+	t.Helper()
+
+	clock := limiter.NewFakeClock(time.Now())
+	l := New(Config{
+		Store: store.NewMemoryStore(),
+		Build: func(st store.Store, _ bool, def limiter.LimitConfig, overrides map[string]limiter.LimitConfig) limiter.Limiter {
+			return limiter.NewFixedWindowLimiter(st, clock, def, overrides)
+		},
+		Clock: clock,
+		Tiers: true,
+	}, p)
+	t.Cleanup(func() { _ = l.Close() })
+
+	return l
+}
+
+func testPolicy() *config.Policy {
+	return &config.Policy{
+		Default: []config.LimitWindow{{Limit: 10, Window: time.Minute}},
+		Tiers: map[string][]config.LimitWindow{
+			"pro": {{Limit: 100, Window: time.Minute}},
+		},
+		Keys: map[string]config.KeyPolicy{
+			"vip": {Tier: "pro"},
+		},
+		Routes: []config.RouteRule{
+			{Pattern: "POST /orders", Cost: 1, Limits: []config.LimitWindow{{Limit: 2, Window: time.Minute}}},
+			{Pattern: "POST /export", Cost: 4},
+		},
+		Exempt: []string{"/health"},
+	}
+}
+
+func allow(t *testing.T, l limiter.Limiter, key string) limiter.RateLimitResult {
+	t.Helper()
+
+	res, err := l.AllowN(context.Background(), key, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return res
+}
+
+func TestRoutesPickTheirLimiterAndCost(t *testing.T) {
+	l := newTestLimiters(t, testPolicy())
+
+	pattern, orders := l.Route(httptest.NewRequest("POST", "/orders", nil))
+	if pattern != "POST /orders" || orders == nil {
+		t.Fatalf("expected the orders route limiter, got %q %v", pattern, orders)
+	}
+	if res := allow(t, orders, "key:abc"); res.Limit != 2 {
+		t.Fatalf("expected the route limit of 2, got %+v", res)
+	}
+
+	// A rule with only a cost counts against the key's own limits.
+	if _, export := l.Route(httptest.NewRequest("POST", "/export", nil)); export != nil {
+		t.Fatalf("expected no limiter for a route without limits, got %v", export)
+	}
+	if cost := l.Cost(httptest.NewRequest("POST", "/export", nil)); cost != 4 {
+		t.Fatalf("expected cost 4, got %d", cost)
+	}
+
+	if !l.Exempt(httptest.NewRequest("GET", "/health", nil)) {
+		t.Fatal("expected /health to be exempt")
+	}
+	if key := l.RouteKey(httptest.NewRequest("GET", "/other", nil)); key != "route:*" {
+		t.Fatalf("expected an unmatched route to key as route:*, got %q", key)
+	}
+}
+
+func TestTiersApplyOnlyToKeysWithoutLimitsOfTheirOwn(t *testing.T) {
+	l := newTestLimiters(t, testPolicy())
+
+	pro := l.Tier("pro", "key:abc")
+	if pro == nil {
+		t.Fatal("expected a registered pro key to get the pro tier limiter")
+	}
+	if res := allow(t, pro, "key:abc"); res.Limit != 100 {
+		t.Fatalf("expected the tier limit of 100, got %+v", res)
+	}
+
+	if got := l.Tier("pro", "key:vip"); got != nil {
+		t.Fatalf("expected a key of the policy to stay on the request limiter, got %v", got)
+	}
+	if got := l.Tier("gold", "key:abc"); got != nil {
+		t.Fatalf("expected an unknown tier to stay on the request limiter, got %v", got)
+	}
+
+	l.ApplyOverrides(map[string]overrides.Override{
+		"abc": {Key: "abc", Policy: config.KeyPolicy{Limits: []config.LimitWindow{{Limit: 3, Window: time.Minute}}}},
+	})
+	if got := l.Tier("pro", "key:abc"); got != nil {
+		t.Fatalf("expected an overridden key to stay on the request limiter, got %v", got)
+	}
+	if res := allow(t, l.Limiter(), "key:abc"); res.Limit != 3 {
+		t.Fatalf("expected the override, spelled as an untagged key, to apply to key:abc, got %+v", res)
+	}
+}
+
+func TestScopesNameRoutesAndTiers(t *testing.T) {
+	l := newTestLimiters(t, testPolicy())
+
+	want := []string{"route:POST /orders", "tier:pro"}
+	if got := l.Names(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected scopes %v, got %v", want, got)
+	}
+
+	for _, name := range want {
+		if _, ok := l.Inspector(name); !ok {
+			t.Fatalf("expected scope %q to have an inspector", name)
+		}
+	}
+
+	for _, name := range []string{"route:POST /export", "tier:gold", "pro", ""} {
+		if _, ok := l.Inspector(name); ok {
+			t.Fatalf("expected no inspector for %q", name)
+		}
+	}
+}
+
+func TestScopesKeepTheirCountersApart(t *testing.T) {
+	l := newTestLimiters(t, testPolicy())
+	ctx := context.Background()
+
+	_, orders := l.Route(httptest.NewRequest("POST", "/orders", nil))
+	allow(t, orders, "key:abc")
+	allow(t, orders, "key:abc")
+	allow(t, l.Tier("pro", "key:abc"), "key:abc")
+
+	route, _ := l.Inspector("route:POST /orders")
+	state, err := route.Inspect(ctx, "key:abc")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if state.Used != 2 {
+		t.Fatalf("expected 2 requests on the route, got %+v", state)
+	}
+
+	state, err = l.Keys().Inspect(ctx, "key:abc")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if state.Used != 0 {
+		t.Fatalf("expected the request limiter to be untouched, got %+v", state)
+	}
+}
+
+func TestReloadKeepsCountersOfSurvivingRoutesAndTiers(t *testing.T) {
+	l := newTestLimiters(t, testPolicy())
+
+	_, orders := l.Route(httptest.NewRequest("POST", "/orders", nil))
+	allow(t, orders, "key:abc")
+	allow(t, orders, "key:abc")
+
+	next := testPolicy()
+	next.Routes[1].Cost = 8
+	next.Exempt = nil
+	next.Tiers["team"] = []config.LimitWindow{{Limit: 50, Window: time.Minute}}
+	l.ApplyPolicy(next)
+
+	_, orders = l.Route(httptest.NewRequest("POST", "/orders", nil))
+	if res := allow(t, orders, "key:abc"); res.Allowed {
+		t.Fatalf("expected the route counters to survive the reload, got %+v", res)
+	}
+	if cost := l.Cost(httptest.NewRequest("POST", "/export", nil)); cost != 8 {
+		t.Fatalf("expected the reloaded cost of 8, got %d", cost)
+	}
+	if l.Exempt(httptest.NewRequest("GET", "/health", nil)) {
+		t.Fatal("expected /health to lose its exemption")
+	}
+	if l.Tier("team", "key:abc") == nil {
+		t.Fatal("expected the new tier to get a limiter")
+	}
+
+	next = testPolicy()
+	next.Routes = next.Routes[1:]
+	delete(next.Tiers, "pro")
+	next.Keys = nil
+	l.ApplyPolicy(next)
+
+	if _, orders := l.Route(httptest.NewRequest("POST", "/orders", nil)); orders != nil {
+		t.Fatalf("expected the dropped route to lose its limiter, got %v", orders)
+	}
+	if got := l.Tier("pro", "key:abc"); got != nil {
+		t.Fatalf("expected the dropped tier to lose its limiter, got %v", got)
+	}
+	if got := l.Names(); len(got) != 0 {
+		t.Fatalf("expected no scopes left, got %v", got)
+	}
+}
+
+func TestValidateRejectsUnknownTiers(t *testing.T) {
+	l := newTestLimiters(t, testPolicy())
+
+	if err := l.ValidateOverride(overrides.Override{Key: "abc", Policy: config.KeyPolicy{Tier: "gold"}}); err == nil {
+		t.Fatal("expected an override of an unknown tier to be rejected")
+	}
+	if err := l.ValidateOverride(overrides.Override{Key: "abc", Policy: config.KeyPolicy{Tier: "pro"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestPolicyAndOverridesSetTheConcurrencyCaps(t *testing.T) {
+	p := testPolicy()
+	p.Concurrency = 2
+	p.Keys["vip"] = config.KeyPolicy{Tier: "pro", Concurrency: 5}
+	l := newTestLimiters(t, p)
+	ctx := context.Background()
+
+	capOf := func(key string) int {
+		t.Helper()
+		lease, err := l.Concurrency().Acquire(ctx, key)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer lease.Release(ctx)
+
+		return lease.Result().Limit
+	}
+
+	if got := capOf("key:abc"); got != 2 {
+		t.Fatalf("expected the default cap of 2, got %d", got)
+	}
+	if got := capOf("key:vip"); got != 5 {
+		t.Fatalf("expected the policy key's cap of 5, got %d", got)
+	}
+
+	l.ApplyOverrides(map[string]overrides.Override{
+		"abc": {Key: "abc", Policy: config.KeyPolicy{Tier: "pro", Concurrency: 9}},
+	})
+	if got := capOf("key:abc"); got != 9 {
+		t.Fatalf("expected the override's cap of 9, got %d", got)
+	}
+
+	next := testPolicy()
+	l.ApplyPolicy(next)
+	if got := capOf("key:vip"); got != 0 {
+		t.Fatalf("expected a reload dropping every cap to leave vip uncapped, got %d", got)
+	}
+}
@@ -0,0 +1,85 @@
+package policy
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bellettati/go-rate-limited-api/internal/limiter"
+	"github.com/bellettati/go-rate-limited-api/internal/store"
+)
+
+// distributed is false for the local fallback store.
+type BuildFunc func(st store.Store, distributed bool, defaultLimit limiter.LimitConfig, overrides map[string]limiter.LimitConfig) limiter.Limiter
+
+type scope struct {
+	limiter  limiter.Limiter
+	primary  *limiter.CompositeLimiter
+	fallback *limiter.CompositeLimiter
+	replicas int
+}
+
+func (l *Limiters) newScope(name string) *scope {
+	compose := func(st store.Store, distributed bool) *limiter.CompositeLimiter {
+		if name != "" {
+			st = store.NewPrefixedStore(st, name)
+		}
+		return limiter.NewCompositeLimiter(func(namespace string, def limiter.LimitConfig, overrides map[string]limiter.LimitConfig) limiter.Limiter {
+			return l.cfg.Build(store.NewPrefixedStore(st, namespace), distributed, def, overrides)
+		}, l.cfg.Clock, nil, nil)
+	}
+
+	s := &scope{primary: compose(l.cfg.Store, l.cfg.Distributed), replicas: l.cfg.Replicas}
+	s.limiter = s.primary
+	if l.cfg.Fallback != nil {
+		s.fallback = compose(l.cfg.Fallback, false)
+		s.limiter = limiter.NewFallbackLimiter(s.primary, s.fallback, l.cfg.Clock)
+	}
+
+	return s
+}
+
+func (s *scope) setPolicy(defaultPolicy []limiter.LimitConfig, keyPolicies map[string][]limiter.LimitConfig) {
+	s.primary.SetPolicy(defaultPolicy, keyPolicies)
+	if s.fallback != nil {
+		s.fallback.SetPolicy(limiter.ScalePolicy(defaultPolicy, keyPolicies, s.replicas))
+	}
+}
+
+func (s *scope) TrackedKeys() int {
+	total := s.primary.TrackedKeys()
+	if s.fallback != nil {
+		total += s.fallback.TrackedKeys()
+	}
+
+	return total
+}
+
+func (s *scope) Close() error {
+	var errs []error
+	errs = append(errs, s.primary.Close())
+	if s.fallback != nil {
+		errs = append(errs, s.fallback.Close())
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *scope) Inspect(ctx context.Context, key string) (limiter.KeyState, error) {
+	return s.primary.Inspect(ctx, key)
+}
+
+// Reset clears key in both limiters, so a key reset while the store is down
+// does not come back once it recovers.
+func (s *scope) Reset(ctx context.Context, key string) error {
+	var errs []error
+	errs = append(errs, s.primary.Reset(ctx, key))
+	if s.fallback != nil {
+		errs = append(errs, s.fallback.Reset(ctx, key))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *scope) TopKeys(ctx context.Context, n int, owns func(string) bool) ([]limiter.KeyState, error) {
+	return s.primary.TopKeys(ctx, n, owns)
+}
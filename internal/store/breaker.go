@@ -0,0 +1,269 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("store circuit breaker is open")
+
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half_open"
+	default:
+		return "unknown"
+	}
+}
+
+type BreakerConfig struct {
+	FailureThreshold int
+	OpenTimeout      time.Duration
+	// Zero uses the caller's context as is.
+	CallTimeout time.Duration
+
+	Now func() time.Time
+}
+
+type BreakerStore struct {
+	next Store
+	cfg  BreakerConfig
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+	// generation counts state changes. A call that finishes after the state
+	// it started in has ended says nothing about the current state.
+	generation uint64
+}
+
+func NewBreakerStore(next Store, cfg BreakerConfig) *BreakerStore {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = 5
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = 10 * time.Second
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+
+	return &BreakerStore{next: next, cfg: cfg}
+}
+
+func (b *BreakerStore) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state
+}
+
+func (b *BreakerStore) before() (generation uint64, probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.cfg.Now().Sub(b.openedAt) < b.cfg.OpenTimeout {
+			return 0, false, ErrCircuitOpen
+		}
+		b.setState(BreakerHalfOpen)
+		b.probing = true
+		return b.generation, true, nil
+	case BreakerHalfOpen:
+		if b.probing {
+			return 0, false, ErrCircuitOpen
+		}
+		b.probing = true
+		return b.generation, true, nil
+	default:
+		return b.generation, false, nil
+	}
+}
+
+// Only the probe decides whether a half-open breaker closes, and calls that
+// started before the last state change are ignored.
+func (b *BreakerStore) after(ctx context.Context, generation uint64, probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if generation != b.generation {
+		return
+	}
+
+	// A caller that gave up is not evidence that the store is unhealthy.
+	if err != nil && ctx.Err() != nil {
+		if probe {
+			b.probing = false
+		}
+		return
+	}
+
+	if probe {
+		b.probing = false
+		b.failures = 0
+		if err == nil {
+			b.setState(BreakerClosed)
+		} else {
+			b.setState(BreakerOpen)
+		}
+		return
+	}
+
+	if err == nil {
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.failures >= b.cfg.FailureThreshold {
+		b.setState(BreakerOpen)
+	}
+}
+
+func (b *BreakerStore) setState(state BreakerState) {
+	b.state = state
+	b.generation++
+	if state == BreakerOpen {
+		b.openedAt = b.cfg.Now()
+	}
+}
+
+func (b *BreakerStore) call(ctx context.Context, fn func(ctx context.Context) error) error {
+	generation, probe, err := b.before()
+	if err != nil {
+		return err
+	}
+
+	callCtx := ctx
+	if b.cfg.CallTimeout > 0 {
+		var cancel context.CancelFunc
+		callCtx, cancel = context.WithTimeout(ctx, b.cfg.CallTimeout)
+		defer cancel()
+	}
+
+	err = fn(callCtx)
+	b.after(ctx, generation, probe, err)
+
+	return err
+}
+
+func (b *BreakerStore) IncrWithTTL(ctx context.Context, key string, ttl time.Duration) (value int64, ttlRemaining time.Duration, err error) {
+	err = b.call(ctx, func(ctx context.Context) error {
+		value, ttlRemaining, err = b.next.IncrWithTTL(ctx, key, ttl)
+		return err
+	})
+	return value, ttlRemaining, err
+}
+
+func (b *BreakerStore) IncrByWithTTL(ctx context.Context, key string, n int64, ttl time.Duration) (value int64, ttlRemaining time.Duration, err error) {
+	err = b.call(ctx, func(ctx context.Context) error {
+		value, ttlRemaining, err = b.next.IncrByWithTTL(ctx, key, n, ttl)
+		return err
+	})
+	return value, ttlRemaining, err
+}
+
+func (b *BreakerStore) FixedWindowIncr(ctx context.Context, key string, cost int64, limit int64, ttl time.Duration) (allowed bool, value int64, err error) {
+	err = b.call(ctx, func(ctx context.Context) error {
+		allowed, value, err = b.next.FixedWindowIncr(ctx, key, cost, limit, ttl)
+		return err
+	})
+	return allowed, value, err
+}
+
+func (b *BreakerStore) SlidingWindowIncr(ctx context.Context, currKey, prevKey string, cost int64, limit int64, prevWeight float64, ttl time.Duration) (allowed bool, curr int64, prev int64, err error) {
+	err = b.call(ctx, func(ctx context.Context) error {
+		allowed, curr, prev, err = b.next.SlidingWindowIncr(ctx, currKey, prevKey, cost, limit, prevWeight, ttl)
+		return err
+	})
+	return allowed, curr, prev, err
+}
+
+func (b *BreakerStore) TakeToken(ctx context.Context, key string, cost float64, capacity float64, refillPerSecond float64, now time.Time, ttl time.Duration) (allowed bool, tokens float64, err error) {
+	err = b.call(ctx, func(ctx context.Context) error {
+		allowed, tokens, err = b.next.TakeToken(ctx, key, cost, capacity, refillPerSecond, now, ttl)
+		return err
+	})
+	return allowed, tokens, err
+}
+
+func (b *BreakerStore) UpdateTAT(ctx context.Context, key string, now time.Time, increment time.Duration, maxAhead time.Duration) (allowed bool, tat time.Time, err error) {
+	err = b.call(ctx, func(ctx context.Context) error {
+		allowed, tat, err = b.next.UpdateTAT(ctx, key, now, increment, maxAhead)
+		return err
+	})
+	return allowed, tat, err
+}
+
+func (b *BreakerStore) AcquireLease(ctx context.Context, key string, leaseID string, limit int64, now time.Time, ttl time.Duration) (acquired bool, held int64, err error) {
+	err = b.call(ctx, func(ctx context.Context) error {
+		acquired, held, err = b.next.AcquireLease(ctx, key, leaseID, limit, now, ttl)
+		return err
+	})
+	return acquired, held, err
+}
+
+func (b *BreakerStore) ReleaseLease(ctx context.Context, key string, leaseID string) error {
+	return b.call(ctx, func(ctx context.Context) error {
+		return b.next.ReleaseLease(ctx, key, leaseID)
+	})
+}
+
+func (b *BreakerStore) Get(ctx context.Context, key string) (value int64, ttlRemaining time.Duration, err error) {
+	err = b.call(ctx, func(ctx context.Context) error {
+		value, ttlRemaining, err = b.next.Get(ctx, key)
+		return err
+	})
+	return value, ttlRemaining, err
+}
+
+func (b *BreakerStore) GetTAT(ctx context.Context, key string) (tat time.Time, err error) {
+	err = b.call(ctx, func(ctx context.Context) error {
+		tat, err = b.next.GetTAT(ctx, key)
+		return err
+	})
+	return tat, err
+}
+
+func (b *BreakerStore) GetBucket(ctx context.Context, key string) (tokens float64, updatedAt time.Time, found bool, err error) {
+	err = b.call(ctx, func(ctx context.Context) error {
+		tokens, updatedAt, found, err = b.next.GetBucket(ctx, key)
+		return err
+	})
+	return tokens, updatedAt, found, err
+}
+
+func (b *BreakerStore) Delete(ctx context.Context, keys ...string) error {
+	return b.call(ctx, func(ctx context.Context) error {
+		return b.next.Delete(ctx, keys...)
+	})
+}
+
+func (b *BreakerStore) Keys(ctx context.Context, prefix string, limit int) (keys []string, err error) {
+	err = b.call(ctx, func(ctx context.Context) error {
+		keys, err = b.next.Keys(ctx, prefix, limit)
+		return err
+	})
+	return keys, err
+}
+
+func (b *BreakerStore) Close() error {
+	return b.next.Close()
+}
@@ -0,0 +1,190 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type stubStore struct {
+	*MemoryStore
+	get func(ctx context.Context) error
+}
+
+func (s stubStore) Get(ctx context.Context, _ string) (int64, time.Duration, error) {
+	return 0, 0, s.get(ctx)
+}
+
+type breakerClock struct {
+	now time.Time
+}
+
+func (c *breakerClock) Now() time.Time {
+	return c.now
+}
+
+func newTestBreaker(threshold int, get func(ctx context.Context) error) (*BreakerStore, *breakerClock) {
+	clock := &breakerClock{now: time.Now()}
+	b := NewBreakerStore(stubStore{MemoryStore: NewMemoryStore(), get: get}, BreakerConfig{
+		FailureThreshold: threshold,
+		OpenTimeout:      10 * time.Second,
+		Now:              clock.Now,
+	})
+
+	return b, clock
+}
+
+var errDown = errors.New("connection refused")
+
+func TestBreaker_TripsOnFailureThreshold(t *testing.T) {
+	calls := 0
+	b, _ := newTestBreaker(3, func(context.Context) error {
+		calls++
+		return errDown
+	})
+
+	for i := 0; i < 2; i++ {
+		b.Get(context.Background(), "k")
+	}
+	if b.State() != BreakerClosed {
+		t.Fatalf("expected breaker to stay closed below the threshold, got %s", b.State())
+	}
+
+	b.Get(context.Background(), "k")
+	if b.State() != BreakerOpen {
+		t.Fatalf("expected breaker to open at the threshold, got %s", b.State())
+	}
+
+	if _, _, err := b.Get(context.Background(), "k"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("expected the open breaker to skip the store, got %d calls", calls)
+	}
+}
+
+func TestBreaker_SuccessResetsFailures(t *testing.T) {
+	fail := true
+	b, _ := newTestBreaker(2, func(context.Context) error {
+		if fail {
+			return errDown
+		}
+		return nil
+	})
+
+	b.Get(context.Background(), "k")
+	fail = false
+	b.Get(context.Background(), "k")
+	fail = true
+	b.Get(context.Background(), "k")
+
+	if b.State() != BreakerClosed {
+		t.Fatalf("expected failures separated by a success not to trip the breaker, got %s", b.State())
+	}
+}
+
+func TestBreaker_AdmitsASingleHalfOpenProbe(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan error)
+	fail := true
+	b, clock := newTestBreaker(1, func(context.Context) error {
+		if fail {
+			return errDown
+		}
+		entered <- struct{}{}
+		return <-release
+	})
+
+	b.Get(context.Background(), "k")
+	fail = false
+	clock.now = clock.now.Add(10 * time.Second)
+
+	probe := make(chan error)
+	go func() {
+		_, _, err := b.Get(context.Background(), "k")
+		probe <- err
+	}()
+	<-entered
+
+	if b.State() != BreakerHalfOpen {
+		t.Fatalf("expected breaker to be half-open during the probe, got %s", b.State())
+	}
+	if _, _, err := b.Get(context.Background(), "k"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected a second call during the probe to be rejected, got %v", err)
+	}
+
+	release <- nil
+	if err := <-probe; err != nil {
+		t.Fatalf("unexpected probe error: %v", err)
+	}
+	if b.State() != BreakerClosed {
+		t.Fatalf("expected a successful probe to close the breaker, got %s", b.State())
+	}
+}
+
+func TestBreaker_FailedProbeReopens(t *testing.T) {
+	b, clock := newTestBreaker(1, func(context.Context) error {
+		return errDown
+	})
+
+	b.Get(context.Background(), "k")
+	clock.now = clock.now.Add(10 * time.Second)
+	b.Get(context.Background(), "k")
+
+	if b.State() != BreakerOpen {
+		t.Fatalf("expected a failed probe to reopen the breaker, got %s", b.State())
+	}
+	if _, _, err := b.Get(context.Background(), "k"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected the reopened breaker to wait a full timeout, got %v", err)
+	}
+}
+
+func TestBreaker_IgnoresResultsOfEarlierStates(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan error)
+	slow := true
+	b, _ := newTestBreaker(1, func(context.Context) error {
+		if slow {
+			slow = false
+			entered <- struct{}{}
+			return <-release
+		}
+		return errDown
+	})
+
+	stale := make(chan error)
+	go func() {
+		_, _, err := b.Get(context.Background(), "k")
+		stale <- err
+	}()
+	<-entered
+
+	b.Get(context.Background(), "k")
+	if b.State() != BreakerOpen {
+		t.Fatalf("expected breaker to open, got %s", b.State())
+	}
+
+	release <- nil
+	<-stale
+
+	if b.State() != BreakerOpen {
+		t.Fatalf("expected a call started while closed not to close the open breaker, got %s", b.State())
+	}
+}
+
+func TestBreaker_CallerCancellationIsNotAFailure(t *testing.T) {
+	b, _ := newTestBreaker(1, func(ctx context.Context) error {
+		return ctx.Err()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, _, err := b.Get(ctx, "k"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the caller's cancellation, got %v", err)
+	}
+	if b.State() != BreakerClosed {
+		t.Fatalf("expected cancellation not to trip the breaker, got %s", b.State())
+	}
+}
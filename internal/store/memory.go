@@ -2,6 +2,7 @@ package store
 
 import (
 	"context"
+	"strings"
 	"sync"
 	"time"
 )
@@ -13,9 +14,17 @@ type memEntry struct {
 	expiresAt time.Time
 }
 
+type memBucket struct {
+	tokens float64
+	updatedAt time.Time
+	expiresAt time.Time
+}
+
 type MemoryStore struct {
 	mu sync.Mutex
 	items map[string]memEntry
+	buckets map[string]memBucket
+	leases map[string]map[string]time.Time
 
 	stopOnce sync.Once
 	stopCh chan struct{}
@@ -32,6 +41,8 @@ func NewMemoryStoreWithCleanupInterval(interval time.Duration) *MemoryStore{
 
 	m := &MemoryStore{
 		items: make(map[string]memEntry),
+		buckets: make(map[string]memBucket),
+		leases: make(map[string]map[string]time.Time),
 		stopCh: make(chan struct{}),
 	}
 
@@ -46,6 +57,261 @@ func (m *MemoryStore) IncrWithTTL(_ context.Context, key string, ttl time.Durati
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	e := m.incrLocked(key, 1, ttl, now)
+	return e.value, e.expiresAt.Sub(now), nil
+}
+
+func (m *MemoryStore) IncrByWithTTL(_ context.Context, key string, n int64, ttl time.Duration) (value int64, ttlRemaining time.Duration, err error) {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if n < 0 {
+		cur := m.valueLocked(key, now)
+		if cur == 0 {
+			return 0, 0, nil
+		}
+		if cur+n < 0 {
+			n = -cur
+		}
+	}
+
+	e := m.incrLocked(key, n, ttl, now)
+	return e.value, e.expiresAt.Sub(now), nil
+}
+
+func (m *MemoryStore) FixedWindowIncr(_ context.Context, key string, cost int64, limit int64, ttl time.Duration) (allowed bool, value int64, err error) {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	value = m.valueLocked(key, now)
+	if value+cost > limit {
+		return false, value, nil
+	}
+
+	e := m.incrLocked(key, cost, ttl, now)
+	return true, e.value, nil
+}
+
+func (m *MemoryStore) SlidingWindowIncr(_ context.Context, currKey, prevKey string, cost int64, limit int64, prevWeight float64, ttl time.Duration) (allowed bool, curr int64, prev int64, err error) {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	curr = m.valueLocked(currKey, now)
+	prev = m.valueLocked(prevKey, now)
+
+	if float64(prev)*prevWeight+float64(curr+cost) > float64(limit) {
+		return false, curr, prev, nil
+	}
+
+	e := m.incrLocked(currKey, cost, ttl, now)
+	return true, e.value, prev, nil
+}
+
+func (m *MemoryStore) TakeToken(_ context.Context, key string, cost float64, capacity float64, refillPerSecond float64, now time.Time, ttl time.Duration) (allowed bool, tokens float64, err error) {
+	realNow := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b, ok := m.buckets[key]
+	if !ok || realNow.After(b.expiresAt) {
+		b = memBucket{tokens: capacity, updatedAt: now}
+	}
+
+	if now.After(b.updatedAt) {
+		b.tokens = min(capacity, b.tokens+refillPerSecond*now.Sub(b.updatedAt).Seconds())
+		b.updatedAt = now
+	}
+
+	if b.tokens >= cost {
+		b.tokens = min(capacity, b.tokens-cost)
+		allowed = true
+	}
+
+	b.expiresAt = realNow.Add(ttl)
+	m.buckets[key] = b
+
+	return allowed, b.tokens, nil
+}
+
+func (m *MemoryStore) UpdateTAT(_ context.Context, key string, now time.Time, increment time.Duration, maxAhead time.Duration) (allowed bool, tat time.Time, err error) {
+	realNow := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tat = now
+	if v := m.valueLocked(key, realNow); v != 0 {
+		if stored := time.Unix(0, v); stored.After(now) {
+			tat = stored
+		}
+	}
+
+	newTat := tat.Add(increment)
+	if newTat.Sub(now) > maxAhead {
+		return false, newTat, nil
+	}
+
+	m.items[key] = memEntry{value: newTat.UnixNano(), expiresAt: realNow.Add(newTat.Sub(now))}
+	return true, newTat, nil
+}
+
+func (m *MemoryStore) AcquireLease(_ context.Context, key string, leaseID string, limit int64, now time.Time, ttl time.Duration) (acquired bool, held int64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	leases := m.leases[key]
+	for id, expiresAt := range leases {
+		if !now.Before(expiresAt) {
+			delete(leases, id)
+		}
+	}
+
+	if _, ok := leases[leaseID]; ok {
+		leases[leaseID] = now.Add(ttl)
+		return true, int64(len(leases)), nil
+	}
+
+	if int64(len(leases)) >= limit {
+		return false, int64(len(leases)), nil
+	}
+
+	if leases == nil {
+		leases = make(map[string]time.Time)
+		m.leases[key] = leases
+	}
+
+	leases[leaseID] = now.Add(ttl)
+	return true, int64(len(leases)), nil
+}
+
+func (m *MemoryStore) ReleaseLease(_ context.Context, key string, leaseID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	leases := m.leases[key]
+	delete(leases, leaseID)
+	if len(leases) == 0 {
+		delete(m.leases, key)
+	}
+
+	return nil
+}
+
+func (m *MemoryStore) Get(_ context.Context, key string) (value int64, ttlRemaining time.Duration, err error) {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	value = m.valueLocked(key, now)
+	if e, ok := m.items[key]; ok && !e.expiresAt.IsZero() {
+		ttlRemaining = e.expiresAt.Sub(now)
+	}
+
+	return value, ttlRemaining, nil
+}
+
+func (m *MemoryStore) GetTAT(_ context.Context, key string) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	v := m.valueLocked(key, time.Now())
+	if v == 0 {
+		return time.Time{}, nil
+	}
+
+	return time.Unix(0, v), nil
+}
+
+func (m *MemoryStore) GetBucket(_ context.Context, key string) (tokens float64, updatedAt time.Time, found bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b, ok := m.buckets[key]
+	if !ok || time.Now().After(b.expiresAt) {
+		return 0, time.Time{}, false, nil
+	}
+
+	return b.tokens, b.updatedAt, true, nil
+}
+
+func (m *MemoryStore) Delete(_ context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range keys {
+		delete(m.items, key)
+		delete(m.buckets, key)
+		delete(m.leases, key)
+	}
+
+	return nil
+}
+
+func (m *MemoryStore) Keys(_ context.Context, prefix string, limit int) ([]string, error) {
+	limit = keysLimit(limit)
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var keys []string
+	add := func(key string) bool {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return len(keys) < limit
+	}
+
+	for key, e := range m.items {
+		if !e.expiresAt.IsZero() && now.After(e.expiresAt) {
+			continue
+		}
+		if !add(key) {
+			return keys, nil
+		}
+	}
+
+	for key, b := range m.buckets {
+		if now.After(b.expiresAt) {
+			continue
+		}
+		if !add(key) {
+			return keys, nil
+		}
+	}
+
+	for key := range m.leases {
+		if !add(key) {
+			return keys, nil
+		}
+	}
+
+	return keys, nil
+}
+
+func (m *MemoryStore) valueLocked(key string, now time.Time) int64 {
+	e, ok := m.items[key]
+	if !ok {
+		return 0
+	}
+
+	if !e.expiresAt.IsZero() && now.After(e.expiresAt) {
+		delete(m.items, key)
+		return 0
+	}
+
+	return e.value
+}
+
+func (m *MemoryStore) incrLocked(key string, n int64, ttl time.Duration, now time.Time) memEntry {
 	if e, ok := m.items[key]; ok {
 		if !e.expiresAt.IsZero() && now.After(e.expiresAt) {
 			delete(m.items, key)
@@ -54,15 +320,21 @@ func (m *MemoryStore) IncrWithTTL(_ context.Context, key string, ttl time.Durati
 
 	e, ok := m.items[key]
 	if !ok {
-		expiresAt := now.Add(ttl)
-		e = memEntry{value: 1, expiresAt: expiresAt}
+		e = memEntry{value: n, expiresAt: now.Add(ttl)}
 		m.items[key] = e
-		return e.value, time.Until(expiresAt), nil
+		return e
 	}
 
-	e.value++
+	e.value += n
 	m.items[key] = e
-	return e.value, time.Until(e.expiresAt), nil
+	return e
+}
+
+func (m *MemoryStore) TrackedKeys() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.items) + len(m.buckets) + len(m.leases)
 }
 
 func (m *MemoryStore) startCleanup(interval time.Duration) {
@@ -90,6 +362,23 @@ func (m *MemoryStore) cleanupExpired() {
 			delete(m.items, k)
 		}
 	}
+
+	for k, b := range m.buckets {
+		if now.After(b.expiresAt) {
+			delete(m.buckets, k)
+		}
+	}
+
+	for k, leases := range m.leases {
+		for id, expiresAt := range leases {
+			if now.After(expiresAt) {
+				delete(leases, id)
+			}
+		}
+		if len(leases) == 0 {
+			delete(m.leases, k)
+		}
+	}
 }
 
 func (m *MemoryStore) Close() error {
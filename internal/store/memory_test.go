@@ -0,0 +1,32 @@
+package store
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore_KeysAppliesTheDefaultAndMaximumLimit(t *testing.T) {
+	mem := NewMemoryStore()
+	ctx := context.Background()
+
+	for i := 0; i < MaxKeysLimit+1; i++ {
+		mem.IncrWithTTL(ctx, "rl:fixed:"+strconv.Itoa(i), time.Minute)
+	}
+
+	for _, tt := range []struct{ limit, want int }{
+		{limit: 0, want: DefaultKeysLimit},
+		{limit: -1, want: DefaultKeysLimit},
+		{limit: 5, want: 5},
+		{limit: MaxKeysLimit + 1, want: MaxKeysLimit},
+	} {
+		keys, err := mem.Keys(ctx, "rl:fixed:", tt.limit)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(keys) != tt.want {
+			t.Fatalf("limit %d: expected %d keys, got %d", tt.limit, tt.want, len(keys))
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Keys are stored as
+//
+//	ns:<len(namespace)>:<namespace>:<key>
+//
+// Limiters write keys starting with "rl:", so a client-supplied key can never
+// reach into a namespace, and the length prefix keeps one namespace from
+// spelling another.
+type PrefixedStore struct {
+	next   Store
+	prefix string
+}
+
+func NewPrefixedStore(next Store, namespace string) *PrefixedStore {
+	return &PrefixedStore{
+		next:   next,
+		prefix: "ns:" + strconv.Itoa(len(namespace)) + ":" + namespace + ":",
+	}
+}
+
+func (p *PrefixedStore) IncrWithTTL(ctx context.Context, key string, ttl time.Duration) (value int64, ttlRemaining time.Duration, err error) {
+	return p.next.IncrWithTTL(ctx, p.prefix+key, ttl)
+}
+
+func (p *PrefixedStore) IncrByWithTTL(ctx context.Context, key string, n int64, ttl time.Duration) (value int64, ttlRemaining time.Duration, err error) {
+	return p.next.IncrByWithTTL(ctx, p.prefix+key, n, ttl)
+}
+
+func (p *PrefixedStore) FixedWindowIncr(ctx context.Context, key string, cost int64, limit int64, ttl time.Duration) (allowed bool, value int64, err error) {
+	return p.next.FixedWindowIncr(ctx, p.prefix+key, cost, limit, ttl)
+}
+
+func (p *PrefixedStore) SlidingWindowIncr(ctx context.Context, currKey, prevKey string, cost int64, limit int64, prevWeight float64, ttl time.Duration) (allowed bool, curr int64, prev int64, err error) {
+	return p.next.SlidingWindowIncr(ctx, p.prefix+currKey, p.prefix+prevKey, cost, limit, prevWeight, ttl)
+}
+
+func (p *PrefixedStore) TakeToken(ctx context.Context, key string, cost float64, capacity float64, refillPerSecond float64, now time.Time, ttl time.Duration) (allowed bool, tokens float64, err error) {
+	return p.next.TakeToken(ctx, p.prefix+key, cost, capacity, refillPerSecond, now, ttl)
+}
+
+func (p *PrefixedStore) UpdateTAT(ctx context.Context, key string, now time.Time, increment time.Duration, maxAhead time.Duration) (allowed bool, tat time.Time, err error) {
+	return p.next.UpdateTAT(ctx, p.prefix+key, now, increment, maxAhead)
+}
+
+func (p *PrefixedStore) AcquireLease(ctx context.Context, key string, leaseID string, limit int64, now time.Time, ttl time.Duration) (acquired bool, held int64, err error) {
+	return p.next.AcquireLease(ctx, p.prefix+key, leaseID, limit, now, ttl)
+}
+
+func (p *PrefixedStore) ReleaseLease(ctx context.Context, key string, leaseID string) error {
+	return p.next.ReleaseLease(ctx, p.prefix+key, leaseID)
+}
+
+func (p *PrefixedStore) Get(ctx context.Context, key string) (value int64, ttlRemaining time.Duration, err error) {
+	return p.next.Get(ctx, p.prefix+key)
+}
+
+func (p *PrefixedStore) GetTAT(ctx context.Context, key string) (time.Time, error) {
+	return p.next.GetTAT(ctx, p.prefix+key)
+}
+
+func (p *PrefixedStore) GetBucket(ctx context.Context, key string) (tokens float64, updatedAt time.Time, found bool, err error) {
+	return p.next.GetBucket(ctx, p.prefix+key)
+}
+
+func (p *PrefixedStore) Delete(ctx context.Context, keys ...string) error {
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = p.prefix + key
+	}
+
+	return p.next.Delete(ctx, prefixed...)
+}
+
+func (p *PrefixedStore) Keys(ctx context.Context, prefix string, limit int) ([]string, error) {
+	keys, err := p.next.Keys(ctx, p.prefix+prefix, limit)
+	for i, key := range keys {
+		keys[i] = strings.TrimPrefix(key, p.prefix)
+	}
+
+	return keys, err
+}
+
+// Close does nothing: the view does not own the store behind it.
+func (p *PrefixedStore) Close() error {
+	return nil
+}
@@ -0,0 +1,41 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPrefixedStore_KeysCarryTheNamespaceAndItsLength(t *testing.T) {
+	mem := NewMemoryStore()
+	ctx := context.Background()
+
+	NewPrefixedStore(mem, "route:POST /orders").IncrWithTTL(ctx, "rl:key:abc", time.Minute)
+
+	if v, _, _ := mem.Get(ctx, "ns:18:route:POST /orders:rl:key:abc"); v != 1 {
+		t.Fatalf("expected the key under ns:18:route:POST /orders:, got %d", v)
+	}
+}
+
+func TestPrefixedStore_NestedViewsAddTheirPrefixesInOrder(t *testing.T) {
+	mem := NewMemoryStore()
+	ctx := context.Background()
+
+	NewPrefixedStore(NewPrefixedStore(mem, "tier:pro"), "1m").IncrWithTTL(ctx, "rl:key:abc", time.Minute)
+
+	if v, _, _ := mem.Get(ctx, "ns:8:tier:pro:ns:2:1m:rl:key:abc"); v != 1 {
+		t.Fatalf("expected the outer namespace first, got %d", v)
+	}
+}
+
+func TestPrefixedStore_NamespacesCannotSpellEachOther(t *testing.T) {
+	mem := NewMemoryStore()
+	ctx := context.Background()
+
+	// Without the length, "a" + "b:c" and "a:b" + "c" would both be "a:b:c".
+	NewPrefixedStore(mem, "a").IncrWithTTL(ctx, "b:c", time.Minute)
+
+	if v, _, _ := NewPrefixedStore(mem, "a:b").Get(ctx, "c"); v != 0 {
+		t.Fatalf("expected namespace a:b not to see the keys of namespace a, got %d", v)
+	}
+}
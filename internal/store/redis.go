@@ -2,7 +2,10 @@ package store
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -58,8 +61,18 @@ func NewRedisStore(cfg RedisConfig) (*RedisStore, error) {
 }
 
 var incrWithTTLLua = redis.NewScript(`
-local v = redis.call('INCR', KEYS[1])
-if v == 1 then
+local n = tonumber(ARGV[2])
+if n < 0 then
+	local cur = tonumber(redis.call('GET', KEYS[1]) or '0')
+	if cur == 0 then
+		return {0, 0}
+	end
+	if cur + n < 0 then
+		n = -cur
+	end
+end
+local v = redis.call('INCRBY', KEYS[1], n)
+if redis.call('PTTL', KEYS[1]) == -1 then
 	redis.call('PEXPIRE', KEYS[1], ARGV[1])
 end
 local ttl = redis.call('PTTL', KEYS[1])
@@ -67,13 +80,17 @@ return {v, ttl}
 `)
 
 func (r *RedisStore) IncrWithTTL(ctx context.Context, key string, ttl time.Duration) (int64, time.Duration, error) {
+	return r.IncrByWithTTL(ctx, key, 1, ttl)
+}
+
+func (r *RedisStore) IncrByWithTTL(ctx context.Context, key string, n int64, ttl time.Duration) (int64, time.Duration, error) {
 	if ttl < 0 {
 		ttl = 0
 	}
 
 	ttlMs := ttl.Milliseconds()
 
-	res, err := incrWithTTLLua.Run(ctx, r.client, []string{key}, ttlMs).Result()
+	res, err := incrWithTTLLua.Run(ctx, r.client, []string{key}, ttlMs, n).Result()
 	if err != nil {
 		return 0, 0, err
 	}
@@ -104,6 +121,330 @@ func (r *RedisStore) IncrWithTTL(ctx context.Context, key string, ttl time.Durat
 	return val, ttlRemaining, nil
 }
 
+var fixedWindowIncrLua = redis.NewScript(`
+local curr = tonumber(redis.call('GET', KEYS[1]) or '0')
+local cost = tonumber(ARGV[1])
+if curr + cost > tonumber(ARGV[2]) then
+	return {0, curr}
+end
+curr = redis.call('INCRBY', KEYS[1], cost)
+if redis.call('PTTL', KEYS[1]) == -1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[3])
+end
+return {1, curr}
+`)
+
+func (r *RedisStore) FixedWindowIncr(ctx context.Context, key string, cost int64, limit int64, ttl time.Duration) (bool, int64, error) {
+	if ttl < 0 {
+		ttl = 0
+	}
+
+	res, err := fixedWindowIncrLua.Run(ctx, r.client, []string{key}, cost, limit, ttl.Milliseconds()).Result()
+	if err != nil {
+		return false, 0, err
+	}
+
+	vals, err := int64Slice(res, 2)
+	if err != nil {
+		return false, 0, err
+	}
+
+	return vals[0] == 1, vals[1], nil
+}
+
+var slidingWindowIncrLua = redis.NewScript(`
+local curr = tonumber(redis.call('GET', KEYS[1]) or '0')
+local prev = tonumber(redis.call('GET', KEYS[2]) or '0')
+local cost = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+local weight = tonumber(ARGV[3])
+if prev * weight + curr + cost > limit then
+	return {0, curr, prev}
+end
+curr = redis.call('INCRBY', KEYS[1], cost)
+if redis.call('PTTL', KEYS[1]) == -1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[4])
+end
+return {1, curr, prev}
+`)
+
+func (r *RedisStore) SlidingWindowIncr(ctx context.Context, currKey, prevKey string, cost int64, limit int64, prevWeight float64, ttl time.Duration) (bool, int64, int64, error) {
+	if ttl < 0 {
+		ttl = 0
+	}
+
+	res, err := slidingWindowIncrLua.Run(ctx, r.client, []string{currKey, prevKey}, cost, limit, prevWeight, ttl.Milliseconds()).Result()
+	if err != nil {
+		return false, 0, 0, err
+	}
+
+	vals, err := int64Slice(res, 3)
+	if err != nil {
+		return false, 0, 0, err
+	}
+
+	return vals[0] == 1, vals[1], vals[2], nil
+}
+
+var takeTokenLua = redis.NewScript(`
+local cost = tonumber(ARGV[1])
+local capacity = tonumber(ARGV[2])
+local rate = tonumber(ARGV[3])
+local now = tonumber(ARGV[4])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = capacity
+	ts = now
+end
+if now > ts then
+	tokens = math.min(capacity, tokens + (now - ts) * rate / 1000000)
+	ts = now
+end
+local allowed = 0
+if tokens >= cost then
+	tokens = math.min(capacity, tokens - cost)
+	allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', string.format('%.0f', ts))
+redis.call('PEXPIRE', KEYS[1], ARGV[5])
+return {allowed, tostring(tokens)}
+`)
+
+func (r *RedisStore) TakeToken(ctx context.Context, key string, cost float64, capacity float64, refillPerSecond float64, now time.Time, ttl time.Duration) (bool, float64, error) {
+	if ttl <= 0 {
+		ttl = time.Millisecond
+	}
+
+	res, err := takeTokenLua.Run(ctx, r.client, []string{key}, cost, capacity, refillPerSecond, now.UnixMicro(), ttl.Milliseconds()).Result()
+	if err != nil {
+		return false, 0, err
+	}
+
+	arr, ok := res.([]interface{})
+	if !ok || len(arr) != 2 {
+		return false, 0, fmt.Errorf("unexpected lua result type=%T value=%v", res, res)
+	}
+
+	allowed, ok := arr[0].(int64)
+	if !ok {
+		return false, 0, fmt.Errorf("unexpected allowed type=%T value=%v", arr[0], arr[0])
+	}
+
+	tokensRaw, ok := arr[1].(string)
+	if !ok {
+		return false, 0, fmt.Errorf("unexpected tokens type=%T value=%v", arr[1], arr[1])
+	}
+
+	tokens, err := strconv.ParseFloat(tokensRaw, 64)
+	if err != nil {
+		return false, 0, fmt.Errorf("unexpected tokens value=%q: %w", tokensRaw, err)
+	}
+
+	return allowed == 1, tokens, nil
+}
+
+var updateTATLua = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local increment = tonumber(ARGV[2])
+local max_ahead = tonumber(ARGV[3])
+local tat = tonumber(redis.call('GET', KEYS[1]) or '0')
+if tat < now then
+	tat = now
+end
+local new_tat = tat + increment
+if new_tat - now > max_ahead then
+	return {0, new_tat}
+end
+local ttl = math.ceil((new_tat - now) / 1000)
+if ttl > 0 then
+	redis.call('SET', KEYS[1], string.format('%.0f', new_tat), 'PX', ttl)
+else
+	redis.call('DEL', KEYS[1])
+end
+return {1, new_tat}
+`)
+
+func (r *RedisStore) UpdateTAT(ctx context.Context, key string, now time.Time, increment time.Duration, maxAhead time.Duration) (bool, time.Time, error) {
+	res, err := updateTATLua.Run(ctx, r.client, []string{key}, now.UnixMicro(), increment.Microseconds(), maxAhead.Microseconds()).Result()
+	if err != nil {
+		return false, time.Time{}, err
+	}
+
+	vals, err := int64Slice(res, 2)
+	if err != nil {
+		return false, time.Time{}, err
+	}
+
+	return vals[0] == 1, time.UnixMicro(vals[1]), nil
+}
+
+var acquireLeaseLua = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local expires_at = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now)
+local held = redis.call('ZCARD', KEYS[1])
+if redis.call('ZSCORE', KEYS[1], ARGV[4]) then
+	redis.call('ZADD', KEYS[1], expires_at, ARGV[4])
+	redis.call('PEXPIRE', KEYS[1], ARGV[5])
+	return {1, held}
+end
+if held >= limit then
+	return {0, held}
+end
+redis.call('ZADD', KEYS[1], expires_at, ARGV[4])
+redis.call('PEXPIRE', KEYS[1], ARGV[5])
+return {1, held + 1}
+`)
+
+func (r *RedisStore) AcquireLease(ctx context.Context, key string, leaseID string, limit int64, now time.Time, ttl time.Duration) (bool, int64, error) {
+	if ttl <= 0 {
+		ttl = time.Millisecond
+	}
+
+	res, err := acquireLeaseLua.Run(ctx, r.client, []string{key}, now.UnixMicro(), now.Add(ttl).UnixMicro(), limit, leaseID, ttl.Milliseconds()).Result()
+	if err != nil {
+		return false, 0, err
+	}
+
+	vals, err := int64Slice(res, 2)
+	if err != nil {
+		return false, 0, err
+	}
+
+	return vals[0] == 1, vals[1], nil
+}
+
+func (r *RedisStore) ReleaseLease(ctx context.Context, key string, leaseID string) error {
+	return r.client.ZRem(ctx, key, leaseID).Err()
+}
+
+var getLua = redis.NewScript(`
+local v = redis.call('GET', KEYS[1])
+if not v then
+	return {0, 0}
+end
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl < 0 then
+	ttl = 0
+end
+return {tonumber(v), ttl}
+`)
+
+func (r *RedisStore) Get(ctx context.Context, key string) (int64, time.Duration, error) {
+	res, err := getLua.Run(ctx, r.client, []string{key}).Result()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	vals, err := int64Slice(res, 2)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return vals[0], time.Duration(vals[1]) * time.Millisecond, nil
+}
+
+func (r *RedisStore) GetTAT(ctx context.Context, key string) (time.Time, error) {
+	v, err := r.client.Get(ctx, key).Int64()
+	if errors.Is(err, redis.Nil) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.UnixMicro(v), nil
+}
+
+func (r *RedisStore) GetBucket(ctx context.Context, key string) (float64, time.Time, bool, error) {
+	vals, err := r.client.HMGet(ctx, key, "tokens", "ts").Result()
+	if err != nil {
+		return 0, time.Time{}, false, err
+	}
+
+	tokensRaw, ok1 := vals[0].(string)
+	tsRaw, ok2 := vals[1].(string)
+	if !ok1 || !ok2 {
+		return 0, time.Time{}, false, nil
+	}
+
+	tokens, err := strconv.ParseFloat(tokensRaw, 64)
+	if err != nil {
+		return 0, time.Time{}, false, fmt.Errorf("unexpected tokens value=%q: %w", tokensRaw, err)
+	}
+
+	ts, err := strconv.ParseInt(tsRaw, 10, 64)
+	if err != nil {
+		return 0, time.Time{}, false, fmt.Errorf("unexpected ts value=%q: %w", tsRaw, err)
+	}
+
+	return tokens, time.UnixMicro(ts), true, nil
+}
+
+func (r *RedisStore) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	return r.client.Del(ctx, keys...).Err()
+}
+
+// SCAN never blocks the server the way KEYS would.
+func (r *RedisStore) Keys(ctx context.Context, prefix string, limit int) ([]string, error) {
+	match := globEscaper.Replace(prefix) + "*"
+	limit = keysLimit(limit)
+
+	var (
+		keys   []string
+		cursor uint64
+	)
+	for {
+		batch, next, err := r.client.Scan(ctx, cursor, match, 500).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, key := range batch {
+			if len(keys) == limit {
+				return keys, nil
+			}
+			keys = append(keys, key)
+		}
+
+		cursor = next
+		if cursor == 0 {
+			return keys, nil
+		}
+	}
+}
+
+var globEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+func int64Slice(res interface{}, n int) ([]int64, error) {
+	arr, ok := res.([]interface{})
+	if !ok || len(arr) != n {
+		return nil, fmt.Errorf("unexpected lua result type=%T value=%v", res, res)
+	}
+
+	vals := make([]int64, n)
+	for i, v := range arr {
+		iv, ok := v.(int64)
+		if !ok {
+			return nil, fmt.Errorf("unexpected lua element %d type=%T value=%v", i, v, v)
+		}
+		vals[i] = iv
+	}
+
+	return vals, nil
+}
+
+func (r *RedisStore) Client() *redis.Client {
+	return r.client
+}
+
 func (r *RedisStore) Close() error {
 	if r.client == nil {
 		return nil
@@ -8,5 +8,49 @@ import (
 type Store interface {
 	IncrWithTTL(ctx context.Context, key string, ttl time.Duration) (value int64, ttlRemaining time.Duration, err error)
 
+	// A negative n never takes the counter below zero and does not create
+	// missing keys.
+	IncrByWithTTL(ctx context.Context, key string, n int64, ttl time.Duration) (value int64, ttlRemaining time.Duration, err error)
+
+	FixedWindowIncr(ctx context.Context, key string, cost int64, limit int64, ttl time.Duration) (allowed bool, value int64, err error)
+
+	SlidingWindowIncr(ctx context.Context, currKey, prevKey string, cost int64, limit int64, prevWeight float64, ttl time.Duration) (allowed bool, curr int64, prev int64, err error)
+
+	// A negative cost returns tokens, up to capacity.
+	TakeToken(ctx context.Context, key string, cost float64, capacity float64, refillPerSecond float64, now time.Time, ttl time.Duration) (allowed bool, tokens float64, err error)
+
+	UpdateTAT(ctx context.Context, key string, now time.Time, increment time.Duration, maxAhead time.Duration) (allowed bool, tat time.Time, err error)
+
+	// Leases expire ttl after now; acquiring a lease that is still held renews
+	// it.
+	AcquireLease(ctx context.Context, key string, leaseID string, limit int64, now time.Time, ttl time.Duration) (acquired bool, held int64, err error)
+
+	ReleaseLease(ctx context.Context, key string, leaseID string) error
+
+	Get(ctx context.Context, key string) (value int64, ttlRemaining time.Duration, err error)
+
+	GetTAT(ctx context.Context, key string) (time.Time, error)
+
+	GetBucket(ctx context.Context, key string) (tokens float64, updatedAt time.Time, found bool, err error)
+
+	Delete(ctx context.Context, keys ...string) error
+
+	// A limit <= 0 means DefaultKeysLimit; no call returns more than
+	// MaxKeysLimit.
+	Keys(ctx context.Context, prefix string, limit int) ([]string, error)
+
 	Close() error
-}
\ No newline at end of file
+}
+
+const (
+	DefaultKeysLimit = 1000
+	MaxKeysLimit     = 10000
+)
+
+func keysLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultKeysLimit
+	}
+
+	return min(limit, MaxKeysLimit)
+}
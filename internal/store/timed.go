@@ -0,0 +1,160 @@
+package store
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+type CallStats struct {
+	calls atomic.Int64
+	nanos atomic.Int64
+}
+
+func (s *CallStats) Calls() int {
+	return int(s.calls.Load())
+}
+
+func (s *CallStats) Duration() time.Duration {
+	return time.Duration(s.nanos.Load())
+}
+
+type callStatsKey struct{}
+
+func WithCallStats(ctx context.Context) (context.Context, *CallStats) {
+	stats := &CallStats{}
+	return context.WithValue(ctx, callStatsKey{}, stats), stats
+}
+
+type ObserveFunc func(op string, took time.Duration, err error)
+
+type TimedStore struct {
+	next    Store
+	observe ObserveFunc
+}
+
+// observe may be nil.
+func NewTimedStore(next Store, observe ObserveFunc) *TimedStore {
+	return &TimedStore{next: next, observe: observe}
+}
+
+func (t *TimedStore) call(ctx context.Context, op string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	took := time.Since(start)
+
+	if stats, ok := ctx.Value(callStatsKey{}).(*CallStats); ok {
+		stats.calls.Add(1)
+		stats.nanos.Add(int64(took))
+	}
+
+	if t.observe != nil {
+		t.observe(op, took, err)
+	}
+
+	return err
+}
+
+func (t *TimedStore) IncrWithTTL(ctx context.Context, key string, ttl time.Duration) (value int64, ttlRemaining time.Duration, err error) {
+	err = t.call(ctx, "IncrWithTTL", func() error {
+		value, ttlRemaining, err = t.next.IncrWithTTL(ctx, key, ttl)
+		return err
+	})
+	return value, ttlRemaining, err
+}
+
+func (t *TimedStore) IncrByWithTTL(ctx context.Context, key string, n int64, ttl time.Duration) (value int64, ttlRemaining time.Duration, err error) {
+	err = t.call(ctx, "IncrByWithTTL", func() error {
+		value, ttlRemaining, err = t.next.IncrByWithTTL(ctx, key, n, ttl)
+		return err
+	})
+	return value, ttlRemaining, err
+}
+
+func (t *TimedStore) FixedWindowIncr(ctx context.Context, key string, cost int64, limit int64, ttl time.Duration) (allowed bool, value int64, err error) {
+	err = t.call(ctx, "FixedWindowIncr", func() error {
+		allowed, value, err = t.next.FixedWindowIncr(ctx, key, cost, limit, ttl)
+		return err
+	})
+	return allowed, value, err
+}
+
+func (t *TimedStore) SlidingWindowIncr(ctx context.Context, currKey, prevKey string, cost int64, limit int64, prevWeight float64, ttl time.Duration) (allowed bool, curr int64, prev int64, err error) {
+	err = t.call(ctx, "SlidingWindowIncr", func() error {
+		allowed, curr, prev, err = t.next.SlidingWindowIncr(ctx, currKey, prevKey, cost, limit, prevWeight, ttl)
+		return err
+	})
+	return allowed, curr, prev, err
+}
+
+func (t *TimedStore) TakeToken(ctx context.Context, key string, cost float64, capacity float64, refillPerSecond float64, now time.Time, ttl time.Duration) (allowed bool, tokens float64, err error) {
+	err = t.call(ctx, "TakeToken", func() error {
+		allowed, tokens, err = t.next.TakeToken(ctx, key, cost, capacity, refillPerSecond, now, ttl)
+		return err
+	})
+	return allowed, tokens, err
+}
+
+func (t *TimedStore) UpdateTAT(ctx context.Context, key string, now time.Time, increment time.Duration, maxAhead time.Duration) (allowed bool, tat time.Time, err error) {
+	err = t.call(ctx, "UpdateTAT", func() error {
+		allowed, tat, err = t.next.UpdateTAT(ctx, key, now, increment, maxAhead)
+		return err
+	})
+	return allowed, tat, err
+}
+
+func (t *TimedStore) AcquireLease(ctx context.Context, key string, leaseID string, limit int64, now time.Time, ttl time.Duration) (acquired bool, held int64, err error) {
+	err = t.call(ctx, "AcquireLease", func() error {
+		acquired, held, err = t.next.AcquireLease(ctx, key, leaseID, limit, now, ttl)
+		return err
+	})
+	return acquired, held, err
+}
+
+func (t *TimedStore) ReleaseLease(ctx context.Context, key string, leaseID string) error {
+	return t.call(ctx, "ReleaseLease", func() error {
+		return t.next.ReleaseLease(ctx, key, leaseID)
+	})
+}
+
+func (t *TimedStore) Get(ctx context.Context, key string) (value int64, ttlRemaining time.Duration, err error) {
+	err = t.call(ctx, "Get", func() error {
+		value, ttlRemaining, err = t.next.Get(ctx, key)
+		return err
+	})
+	return value, ttlRemaining, err
+}
+
+func (t *TimedStore) GetTAT(ctx context.Context, key string) (tat time.Time, err error) {
+	err = t.call(ctx, "GetTAT", func() error {
+		tat, err = t.next.GetTAT(ctx, key)
+		return err
+	})
+	return tat, err
+}
+
+func (t *TimedStore) GetBucket(ctx context.Context, key string) (tokens float64, updatedAt time.Time, found bool, err error) {
+	err = t.call(ctx, "GetBucket", func() error {
+		tokens, updatedAt, found, err = t.next.GetBucket(ctx, key)
+		return err
+	})
+	return tokens, updatedAt, found, err
+}
+
+func (t *TimedStore) Delete(ctx context.Context, keys ...string) error {
+	return t.call(ctx, "Delete", func() error {
+		return t.next.Delete(ctx, keys...)
+	})
+}
+
+func (t *TimedStore) Keys(ctx context.Context, prefix string, limit int) (keys []string, err error) {
+	err = t.call(ctx, "Keys", func() error {
+		keys, err = t.next.Keys(ctx, prefix, limit)
+		return err
+	})
+	return keys, err
+}
+
+func (t *TimedStore) Close() error {
+	return t.next.Close()
+}